@@ -0,0 +1,52 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// auditLevel is the pseudo log level of audit records; it is never filtered
+// and it is rendered as "[A]".
+const auditLevel LogLevel = -1
+
+var (
+	logAuditStream     io.Writer
+	logAuditStreamLock sync.RWMutex
+)
+
+// SetAuditStream sets the stream audit records are written to; if nil, which
+// is the default, audit records are written to the current log stream.
+func SetAuditStream(stream io.Writer) {
+	logAuditStreamLock.Lock()
+	defer logAuditStreamLock.Unlock()
+	logAuditStream = stream
+}
+
+// GetAuditStream returns the stream audit records are written to, or nil if
+// they are written to the current log stream.
+func GetAuditStream() io.Writer {
+	logAuditStreamLock.RLock()
+	defer logAuditStreamLock.RUnlock()
+	return logAuditStream
+}
+
+// Audit writes an audit record to the audit stream, appending a new line; audit
+// records are always written, regardless of the current log level, so that
+// they cannot be accidentally suppressed, and are tagged with "[A]".
+func Audit(format string, args ...interface{}) (int, error) {
+	message, fields := sprintf(format, args)
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	render(buffer, newRecord(current(), auditLevel, 1, nil, time.Time{}, message, fields))
+	stream := GetAuditStream()
+	if stream == nil {
+		flushPending()
+		stream = GetStream()
+	}
+	return stream.Write(buffer.Bytes())
+}
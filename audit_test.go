@@ -0,0 +1,35 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetAuditStream(GetAuditStream())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(NoneLevel)
+	Audit("user %s logged in", "admin")
+	if !strings.HasPrefix(buffer.String(), "[A] ") || !strings.Contains(buffer.String(), "user admin logged in") {
+		t.Errorf("unexpected audit record on log stream %q", buffer.String())
+	}
+
+	buffer.Reset()
+	audit := &bytes.Buffer{}
+	SetAuditStream(audit)
+	Audit("user %s logged out", "admin")
+	if buffer.Len() != 0 {
+		t.Errorf("unexpected output on log stream %q", buffer.String())
+	}
+	if !strings.HasPrefix(audit.String(), "[A] ") || !strings.Contains(audit.String(), "user admin logged out") {
+		t.Errorf("unexpected audit record %q", audit.String())
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// batch holds the lines that are waiting to be written to the stream
+// together.
+type batch struct {
+	lock    sync.Mutex
+	stream  io.Writer
+	pending []byte
+	lines   int
+	timer   *time.Timer
+}
+
+var (
+	logBatch         batch
+	logBatchMaxLines int
+	logBatchMaxDelay time.Duration
+	logBatchLock     sync.RWMutex
+)
+
+// SetWriteBatch makes the logger coalesce lines and write them to the stream
+// together, with a single call, when maxLines lines are pending or maxDelay has
+// elapsed since the first of them was logged, whichever comes first; this
+// reduces the number of system calls under high throughput while bounding the
+// latency. Messages are still formatted synchronously, and fatal and panic
+// messages are written immediately, along with the pending lines; Flush writes
+// the pending lines as well. Since writes are deferred, the logging functions
+// return the length of the line and a nil error, while failures are reported to
+// the write error handler, and the lines are written to the fallback stream, if
+// any. A maxLines of 1 or less, which is the default, disables batching; a
+// maxDelay of 0 or less disables the time limit.
+func SetWriteBatch(maxLines int, maxDelay time.Duration) {
+	defer changed()
+	flushBatch()
+	logBatchLock.Lock()
+	defer logBatchLock.Unlock()
+	logBatchMaxLines = maxLines
+	logBatchMaxDelay = maxDelay
+}
+
+// GetWriteBatch returns the maximum number of lines and the maximum delay of
+// batched writes.
+func GetWriteBatch() (int, time.Duration) {
+	logBatchLock.RLock()
+	defer logBatchLock.RUnlock()
+	return logBatchMaxLines, logBatchMaxDelay
+}
+
+// batchWrite writes the line of a message at the given level to the stream of
+// the settings, either immediately or, if batching is enabled, as part of a
+// batch; lines for streams that need the level are never batched.
+func batchWrite(s *settings, level LogLevel, line []byte) (int, error) {
+	stream, maxLines, maxDelay := s.stream, s.batchLines, s.batchDelay
+	if w, ok := stream.(LevelWriter); ok {
+		return w.WriteLevel(level, line)
+	}
+	if maxLines <= 1 {
+		return stream.Write(line)
+	}
+	logBatch.lock.Lock()
+	defer logBatch.lock.Unlock()
+	if logBatch.stream != stream {
+		// lines pending for the previous stream must not end up on this one
+		logBatch.flush()
+		logBatch.stream = stream
+	}
+	logBatch.pending = append(logBatch.pending, line...)
+	logBatch.lines++
+	if logBatch.lines >= maxLines {
+		logBatch.flush()
+	} else if logBatch.timer == nil && maxDelay > 0 {
+		logBatch.timer = time.AfterFunc(maxDelay, func() {
+			flushBatch()
+		})
+	}
+	return len(line), nil
+}
+
+// flushBatch writes the pending lines, if any, to the stream.
+func flushBatch() error {
+	logBatch.lock.Lock()
+	defer logBatch.lock.Unlock()
+	return logBatch.flush()
+}
+
+// flushPending writes the records held by the formatter and the lines batched
+// by the logger, if any, so that whatever is written directly to the stream
+// next comes after them; failures are reported to the write error handler.
+func flushPending() {
+	flushFormatter()
+	flushBatch()
+}
+
+// flush writes the pending lines to the stream; failures are reported to the
+// write error handler and the lines are written to the fallback stream, if
+// any. It must be called with the lock held.
+func (b *batch) flush() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return nil
+	}
+	_, err := b.stream.Write(b.pending)
+	if err != nil {
+		if handler := GetWriteErrorHandler(); handler != nil {
+			handler(err)
+		}
+		if fallback := GetFallbackStream(); fallback != nil {
+			fallback.Write(b.pending)
+		}
+	}
+	b.pending = b.pending[:0]
+	b.lines = 0
+	return err
+}
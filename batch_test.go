@@ -0,0 +1,115 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingWriter counts the calls to Write.
+type countingWriter struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.writes++
+	return w.buffer.Write(p)
+}
+
+func (w *countingWriter) state() (string, int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.buffer.String(), w.writes
+}
+
+func TestWriteBatch(t *testing.T) {
+	defer SetWriteBatch(GetWriteBatch())
+	writer := &countingWriter{}
+	defer WithWriter(writer, false)()
+
+	SetWriteBatch(3, time.Hour)
+	Infoln("one")
+	Infoln("two")
+	if _, writes := writer.state(); writes != 0 {
+		t.Fatalf("expected no writes, got %d", writes)
+	}
+	Infoln("three")
+	if output, writes := writer.state(); writes != 1 || strings.Count(output, "\n") != 3 {
+		t.Fatalf("expected 3 lines in 1 write, got %d writes: %q", writes, output)
+	}
+
+	Infoln("four")
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, writes := writer.state(); writes != 2 {
+		t.Errorf("expected Flush to write the pending line, got %d writes", writes)
+	}
+
+	Infoln("five")
+	Fatalln("six")
+	if output, writes := writer.state(); writes != 3 || !strings.Contains(output, "five") {
+		t.Errorf("expected fatal messages to be written immediately, got %d writes", writes)
+	}
+
+	SetWriteBatch(100, 10*time.Millisecond)
+	Infoln("seven")
+	deadline := time.Now().Add(time.Second)
+	for {
+		if output, _ := writer.state(); strings.Contains(output, "seven") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the pending line to be written after the delay")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteBatchStreamChange(t *testing.T) {
+	defer flushBatch()
+	first, second := &countingWriter{}, &countingWriter{}
+	s := *current()
+	s.batchLines, s.batchDelay = 10, 0
+
+	// a record taken with the settings of another stream, e.g. by a goroutine
+	// racing with SetStream
+	s.stream = first
+	batchWrite(&s, InfoLevel, []byte("first\n"))
+	s.stream = second
+	batchWrite(&s, InfoLevel, []byte("second\n"))
+	flushBatch()
+	if output, _ := first.state(); output != "first\n" {
+		t.Errorf("unexpected output on the first stream %q", output)
+	}
+	if output, _ := second.state(); output != "second\n" {
+		t.Errorf("unexpected output on the second stream %q", output)
+	}
+}
+
+func TestWriteBatchDirectWrites(t *testing.T) {
+	defer SetWriteBatch(GetWriteBatch())
+	writer := &countingWriter{}
+	defer WithWriter(writer, false)()
+
+	SetWriteBatch(10, time.Hour)
+	Infoln("batched")
+	Rawln("raw")
+	Infoln("batched again")
+	Audit("audited")
+	Flush()
+	output, _ := writer.state()
+	if lines := strings.Split(output, "\n"); len(lines) != 5 || !strings.Contains(lines[0], "batched") || lines[1] != "raw" || !strings.Contains(lines[2], "batched again") || !strings.Contains(lines[3], "audited") {
+		t.Errorf("expected lines in the order they were logged, got %q", output)
+	}
+}
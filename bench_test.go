@@ -0,0 +1,211 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// benchmarkRecord is a representative record for formatting benchmarks.
+var benchmarkRecord = &Record{
+	Level:    InfoLevel,
+	Time:     time.Now(),
+	Function: "log.BenchmarkLineAssembly",
+	File:     "bench_test.go",
+	Line:     42,
+	Message:  "request served",
+	Fields:   []Field{Str("method", "GET"), Int("status", 200), Dur("latency", 12300*time.Microsecond)},
+}
+
+// BenchmarkLineAssembly compares assembling lines in pooled buffers against
+// allocating a new buffer for each line, under concurrent load.
+func BenchmarkLineAssembly(b *testing.B) {
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				buffer := getBuffer()
+				renderJSON(buffer, benchmarkRecord)
+				io.Discard.Write(buffer.Bytes())
+				putBuffer(buffer)
+			}
+		})
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				buffer := &bytes.Buffer{}
+				renderJSON(buffer, benchmarkRecord)
+				io.Discard.Write(buffer.Bytes())
+			}
+		})
+	})
+}
+
+// BenchmarkFormat measures rendering a representative record in each of the
+// built-in formats and through the JSON batch formatter, so that the cost of
+// choosing a format is known and regressions in any of them show up.
+func BenchmarkFormat(b *testing.B) {
+	for _, format := range []struct {
+		name   string
+		format LogFormat
+	}{
+		{"text", FormatText},
+		{"json", FormatJSON},
+		{"logfmt", FormatLogfmt},
+		{"gelf", FormatGELF},
+		{"journal", FormatJournal},
+	} {
+		b.Run(format.name, func(b *testing.B) {
+			buffer := &bytes.Buffer{}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buffer.Reset()
+				renderFormat(buffer, benchmarkRecord, format.format)
+			}
+		})
+	}
+	b.Run("json-batch", func(b *testing.B) {
+		formatter := NewJSONBatchFormatter(100, 0)
+		buffer := &bytes.Buffer{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buffer.Reset()
+			formatter.Format(buffer, benchmarkRecord)
+		}
+	})
+}
+
+// sink is a writer that throws everything away like io.Discard, without
+// triggering the logger's short-circuit for io.Discard.
+type sink struct{}
+
+func (sink) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// BenchmarkInfofParallel measures concurrent logging in each output format.
+func BenchmarkInfofParallel(b *testing.B) {
+	defer SetFormat(GetFormat())
+	defer WithWriter(sink{}, false)()
+
+	for _, format := range []struct {
+		name   string
+		format LogFormat
+	}{
+		{"text", FormatText},
+		{"json", FormatJSON},
+		{"logfmt", FormatLogfmt},
+	} {
+		b.Run(format.name, func(b *testing.B) {
+			SetFormat(format.format)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					Infof("request served in %d ms", 12, Str("method", "GET"), Int("status", 200))
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkPrintf measures Printf with a format that starts with a level tag,
+// which is stripped before delegating to the function for that level, against
+// compiling the pattern of the tag on every call, as Printf used to do.
+func BenchmarkPrintf(b *testing.B) {
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	defer WithWriter(sink{}, false)()
+
+	SetPrintCallerInfo(false)
+	SetPrintSourceInfo(SourceInfoNone)
+	b.Run("precompiled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Printf("[I] request served in %d ms", 12)
+		}
+	})
+	b.Run("compiled per call", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			re := regexp.MustCompile(levelTagPattern.String())
+			logf(InfoLevel, 0, re.ReplaceAllString("[I] request served in %d ms", ""), []interface{}{12})
+		}
+	})
+}
+
+// BenchmarkInfof measures logging a message with the runtime information on,
+// which requires walking the stack, and off, which is the fast path.
+func BenchmarkInfof(b *testing.B) {
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	defer WithWriter(sink{}, false)()
+
+	b.Run("caller+source", func(b *testing.B) {
+		SetPrintCallerInfo(true)
+		SetPrintSourceInfo(SourceInfoShort)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Infof("request served in %d ms", 12)
+		}
+	})
+	b.Run("plain", func(b *testing.B) {
+		SetPrintCallerInfo(false)
+		SetPrintSourceInfo(SourceInfoNone)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Infof("request served in %d ms", 12)
+		}
+	})
+}
+
+// BenchmarkDisabled is the baseline for messages that are not written, either
+// because their level is disabled or because the stream is io.Discard: neither
+// should format the message nor allocate.
+func BenchmarkDisabled(b *testing.B) {
+	defer SetLevel(GetLevel())
+
+	b.Run("level", func(b *testing.B) {
+		defer WithWriter(sink{}, false)()
+		SetLevel(ErrorLevel)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Infof("request served in %d ms", 12)
+		}
+	})
+	b.Run("discard", func(b *testing.B) {
+		defer WithWriter(io.Discard, false)()
+		SetLevel(DebugLevel)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Infof("request served in %d ms", 12)
+		}
+	})
+}
+
+// BenchmarkContention measures many goroutines logging at once with the
+// runtime information off, so that the cost of reading the settings for each
+// message, and any contention among goroutines doing so, is not hidden by the
+// stack walk; compare runs with different -cpu values.
+func BenchmarkContention(b *testing.B) {
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	defer WithWriter(sink{}, false)()
+
+	SetPrintCallerInfo(false)
+	SetPrintSourceInfo(SourceInfoNone)
+	b.ReportAllocs()
+	b.SetParallelism(4)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Infof("request served in %d ms", 12, Str("method", "GET"))
+		}
+	})
+}
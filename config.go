@@ -0,0 +1,114 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"os"
+)
+
+// Config is a snapshot of the main settings of the logger, e.g. for
+// diagnostics or to be exposed by a configuration endpoint.
+type Config struct {
+	// Level is the current log level.
+	Level LogLevel
+	// Format is the format of log messages.
+	Format LogFormat
+	// TimeFormat is the format of the time of log messages.
+	TimeFormat string
+	// Colorise is whether colours were requested for the stream.
+	Colorise bool
+	// Coloured is whether colours are actually applied, i.e. if they were
+	// requested and the stream is a terminal, or they are forced.
+	Coloured bool
+	// PrintCallerInfo is whether the calling function is printed.
+	PrintCallerInfo bool
+	// CallerStyle is how the calling function is printed.
+	CallerStyle int8
+	// PrintSourceInfo is whether and how the source file and line are printed.
+	PrintSourceInfo int8
+	// Stream is a description of the stream: the name of the file for files
+	// (e.g. /dev/stderr), or its type otherwise.
+	Stream string
+}
+
+// GetConfig returns a snapshot of the main settings of the logger, taken from
+// the settings messages are currently logged with, so that the settings other
+// than the level are consistent with one another; the level is read on its own,
+// since a variable bound with BindLevel can be changed by external code at any
+// time.
+func GetConfig() Config {
+	s := current()
+	return Config{
+		Level:           LogLevel(logLevel.Load().Load()),
+		Format:          s.format,
+		TimeFormat:      s.timeFormat,
+		Colorise:        s.colorise,
+		Coloured:        s.colours != nil,
+		PrintCallerInfo: s.printCallerInfo,
+		CallerStyle:     s.callerStyle,
+		PrintSourceInfo: s.printSourceInfo,
+		Stream:          describe(s.raw),
+	}
+}
+
+// LogConfig writes a message at the given level summarising the main settings
+// of the logger (see GetConfig), e.g.
+//
+//	logging configured level=info format=text colour=true caller=true source=short time_format=15:04:05 stream=/dev/stderr
+//
+// in text output, so that operators reading the log can tell how logging is
+// set up, e.g. why debug messages are missing; it is meant to be called once,
+// at startup. Messages at PanicLevel are written but do not panic.
+func LogConfig(level LogLevel) (int, error) {
+	if level < TraceLevel || level >= NoneLevel || !isEnabled(level) || discarded(level) {
+		return 0, nil
+	}
+	config := GetConfig()
+	return output(level, 1, "logging configured", []Field{
+		Str("level", config.Level.name()),
+		Str("format", formatName(config.Format)),
+		Bool("colour", config.Coloured),
+		Bool("caller", config.PrintCallerInfo),
+		Str("source", sourceInfoName(config.PrintSourceInfo)),
+		Str("time_format", config.TimeFormat),
+		Str("stream", config.Stream),
+	})
+}
+
+// formatName returns the name of the format.
+func formatName(format LogFormat) string {
+	switch format {
+	case FormatJSON:
+		return "json"
+	case FormatLogfmt:
+		return "logfmt"
+	case FormatGELF:
+		return "gelf"
+	case FormatJournal:
+		return "journal"
+	}
+	return "text"
+}
+
+// sourceInfoName returns the name of the source info setting, as accepted by
+// SetPrintSourceInfoByName.
+func sourceInfoName(mode int8) string {
+	switch mode {
+	case SourceInfoShort:
+		return "short"
+	case SourceInfoLong:
+		return "long"
+	}
+	return "none"
+}
+
+// describe returns a description of the stream.
+func describe(stream interface{}) string {
+	if file, ok := stream.(*os.File); ok {
+		return file.Name()
+	}
+	return fmt.Sprintf("%T", stream)
+}
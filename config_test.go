@@ -0,0 +1,54 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGetConfig(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetFormat(GetFormat())
+	defer WithWriter(&bytes.Buffer{}, true)()
+
+	SetLevel(WarnLevel)
+	SetFormat(FormatJSON)
+	config := GetConfig()
+	if config.Level != WarnLevel || config.Format != FormatJSON || config.TimeFormat != GetTimeFormat() {
+		t.Errorf("unexpected settings in %+v", config)
+	}
+	if !config.Colorise || config.Coloured || config.Stream != "*bytes.Buffer" {
+		t.Errorf("unexpected stream settings in %+v", config)
+	}
+
+	SetStream(os.Stdout, false)
+	if config := GetConfig(); config.Stream != os.Stdout.Name() || config.Colorise {
+		t.Errorf("unexpected stream settings in %+v", config)
+	}
+}
+
+func TestLogConfig(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetFormat(GetFormat())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(InfoLevel)
+	SetFormat(FormatLogfmt)
+	SetPrintSourceInfo(SourceInfoNone)
+	LogConfig(DebugLevel)
+	if buffer.Len() != 0 {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+	LogConfig(InfoLevel)
+	expected := ` msg="logging configured" level=info format=logfmt colour=false caller=true source=none time_format=`
+	if !strings.Contains(buffer.String(), expected) || !strings.HasSuffix(buffer.String(), " stream=*bytes.Buffer\n") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
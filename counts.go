@@ -0,0 +1,37 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync/atomic"
+)
+
+// logCounts holds the number of messages emitted at each level.
+var logCounts [NoneLevel]atomic.Uint64
+
+// Counts returns how many messages have been emitted at each level since the
+// program started or since the last call to ResetCounts; messages discarded
+// because of the current log level are not counted.
+func Counts() map[LogLevel]uint64 {
+	counts := make(map[LogLevel]uint64, len(logCounts))
+	for level := TraceLevel; level < NoneLevel; level++ {
+		counts[level] = logCounts[level].Load()
+	}
+	return counts
+}
+
+// ResetCounts resets the number of messages emitted at each level.
+func ResetCounts() {
+	for level := TraceLevel; level < NoneLevel; level++ {
+		logCounts[level].Store(0)
+	}
+}
+
+// count increments the number of messages emitted at the given level.
+func count(level LogLevel) {
+	if level >= TraceLevel && level < NoneLevel {
+		logCounts[level].Add(1)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCounts(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer WithWriter(&bytes.Buffer{}, false)()
+
+	SetLevel(InfoLevel)
+	ResetCounts()
+	Debugf("not counted")
+	Infof("counted")
+	Errorln("counted")
+	Errorf("counted")
+
+	counts := Counts()
+	expected := map[LogLevel]uint64{DebugLevel: 0, InfoLevel: 1, ErrorLevel: 2}
+	for level, n := range expected {
+		if counts[level] != n {
+			t.Errorf("expected %d messages at level %s, got %d", n, level, counts[level])
+		}
+	}
+
+	ResetCounts()
+	if counts := Counts(); counts[ErrorLevel] != 0 {
+		t.Errorf("expected counts to be reset, got %v", counts)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"os"
+	"runtime"
+	"sync"
+)
+
+var (
+	logCrashFile     string
+	logCrashFileLock sync.RWMutex
+)
+
+var (
+	logFatalToStderr     = true
+	logFatalToStderrLock sync.RWMutex
+)
+
+// SetCrashFile sets the path of a file to which fatal and panic messages are
+// also appended, followed by the stack trace of the calling goroutine, so that
+// they survive for post-mortem analysis even if the stream is a transient pipe;
+// the file is created if needed and it is only opened when such a message is
+// logged. Failures to write the file are ignored. An empty path, which is the
+// default, disables the feature.
+func SetCrashFile(path string) {
+	defer changed()
+	logCrashFileLock.Lock()
+	defer logCrashFileLock.Unlock()
+	logCrashFile = path
+}
+
+// GetCrashFile returns the path of the file to which fatal and panic messages
+// are also appended, or an empty string if there is none.
+func GetCrashFile() string {
+	logCrashFileLock.RLock()
+	defer logCrashFileLock.RUnlock()
+	return logCrashFile
+}
+
+// SetFatalToStderr sets whether fatal and panic messages are also written to
+// os.Stderr, uncoloured, in addition to the stream, so that a terminating error
+// is visible on the console even when the stream is a file; it is enabled by
+// default. Nothing is written twice when the stream is os.Stderr itself, nor
+// when it is io.Discard. Failures to write to os.Stderr are ignored.
+func SetFatalToStderr(enabled bool) {
+	defer changed()
+	logFatalToStderrLock.Lock()
+	defer logFatalToStderrLock.Unlock()
+	logFatalToStderr = enabled
+}
+
+// GetFatalToStderr returns whether fatal and panic messages are also written
+// to os.Stderr.
+func GetFatalToStderr() bool {
+	logFatalToStderrLock.RLock()
+	defer logFatalToStderrLock.RUnlock()
+	return logFatalToStderr
+}
+
+// toStderr writes the record, uncoloured, to os.Stderr, if fatal and panic
+// messages are also written there and the stream is neither os.Stderr nor
+// io.Discard.
+func toStderr(r *Record) {
+	s := r.config()
+	if !s.fatalToStderr || s.discard || s.raw == os.Stderr {
+		return
+	}
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	render(buffer, r)
+	os.Stderr.Write(buffer.Bytes())
+}
+
+// crash appends the record, uncoloured, and the stack trace of the calling
+// goroutine to the crash file, if any.
+func crash(r *Record) {
+	path := GetCrashFile()
+	if path == "" {
+		return
+	}
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	render(buffer, r)
+	buffer.Write(stack())
+	buffer.WriteByte('\n')
+	// serialise writers, so that reports from concurrent crashes do not mix
+	logCrashFileLock.Lock()
+	defer logCrashFileLock.Unlock()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.Write(buffer.Bytes())
+}
+
+// stack returns the stack trace of the calling goroutine.
+func stack() []byte {
+	buffer := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buffer, false)
+		if n < len(buffer) {
+			return buffer[:n]
+		}
+		buffer = make([]byte, 2*len(buffer))
+	}
+}
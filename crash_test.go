@@ -0,0 +1,77 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCrashFile(t *testing.T) {
+	defer SetCrashFile(GetCrashFile())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	path := filepath.Join(t.TempDir(), "crash.log")
+	SetCrashFile(path)
+	Errorf("not a crash")
+	Fatalf("out of memory")
+	func() {
+		defer func() {
+			recover()
+		}()
+		Panicln("corrupted state")
+	}()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crash := string(data)
+	if strings.Contains(crash, "not a crash") {
+		t.Errorf("unexpected error message in crash file %q", crash)
+	}
+	if !strings.Contains(crash, "[F] ") || !strings.Contains(crash, "out of memory") || !strings.Contains(crash, "corrupted state") {
+		t.Errorf("missing messages in crash file %q", crash)
+	}
+	if strings.Count(crash, " [running]:") != 2 || !strings.Contains(crash, "TestCrashFile") {
+		t.Errorf("missing stack traces in crash file %q", crash)
+	}
+}
+
+func TestFatalToStderr(t *testing.T) {
+	defer SetFatalToStderr(GetFatalToStderr())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	stderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+	defer func(previous *os.File) {
+		os.Stderr = previous
+	}(os.Stderr)
+	os.Stderr = stderr
+
+	Errorf("not fatal")
+	Fatalf("out of memory")
+	SetFatalToStderr(false)
+	Fatalf("not teed")
+
+	data, err := os.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output := string(data); !strings.HasPrefix(output, "[F] ") || !strings.Contains(output, "out of memory") || strings.Count(output, "\n") != 1 {
+		t.Errorf("unexpected output on stderr %q", output)
+	}
+	if strings.Count(buffer.String(), "\n") != 3 {
+		t.Errorf("unexpected output on the stream %q", buffer.String())
+	}
+}
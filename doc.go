@@ -0,0 +1,23 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package log is a simple, levelled logger writing to a configurable stream,
+// with optional colours, runtime information and structured fields.
+//
+// All the logging functions return an (int, error) pair with the same meaning
+// as io.Writer's: the int is the total number of bytes written to the stream
+// for the message, i.e. including the level tag, the time, the runtime
+// information, the fields, any colour escape codes and the line ending, not
+// just the caller's message; the error is the one returned by the stream, if
+// any. When a message is not written, because its level is disabled, its
+// source is muted or the stream is io.Discard, they return 0 and nil. The
+// Panic functions never return, since they panic after writing the message.
+//
+// Messages may be held back before they reach their destination, by a
+// BatchFormatter, by SetWriteBatch or by a buffered stream such as a
+// *bufio.Writer; Flush writes them all, and FlushContext does the same but
+// gives up between its steps when its context is done. Since the lines are
+// already rendered by then, what is left behind is reported in bytes, not in
+// messages.
+package log
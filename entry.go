@@ -0,0 +1,230 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"time"
+)
+
+// Entry is a set of fields, possibly organised in groups, that are added to
+// every message logged through it, before the fields passed at the call site;
+// entries are immutable, so they can be shared among goroutines and extended
+// freely, e.g.
+//
+//	request := log.WithGroup("http").WithFields(log.Str("method", "GET"))
+//	request.Infof("served", log.Int("status", 200))
+//
+// logs "served http.method=GET http.status=200" in text output and nests the
+// fields under an "http" object in JSON output.
+type Entry struct {
+	// fields holds the fields of the entry, where groups are fields whose
+	// value is a []Field.
+	fields []Field
+	// groups is the path of the open groups, i.e. the group that fields added
+	// later go into.
+	groups []string
+}
+
+// WithFields returns an Entry holding the given fields.
+func WithFields(fields ...Field) *Entry {
+	return (&Entry{}).WithFields(fields...)
+}
+
+// With returns an Entry holding a field with the given key and value; it allows
+// to chain fields fluently, e.g.
+//
+//	log.With("user", name).With("attempt", n).Warnf("login failed")
+//
+// and nothing is logged until one of the logging methods is called.
+func With(key string, value interface{}) *Entry {
+	return (&Entry{}).With(key, value)
+}
+
+// WithGroup returns an Entry whose fields, including those passed at the call
+// site of the logging functions, are grouped under the given name, like
+// slog.Logger.WithGroup does.
+func WithGroup(name string) *Entry {
+	return (&Entry{}).WithGroup(name)
+}
+
+// WithFields returns a new Entry holding the entry's fields and the given ones,
+// which are added to the innermost group, if any.
+func (e *Entry) WithFields(fields ...Field) *Entry {
+	return &Entry{fields: e.merge(fields), groups: e.groups}
+}
+
+// With returns a new Entry holding the entry's fields and a field with the
+// given key and value, which is added to the innermost group, if any.
+func (e *Entry) With(key string, value interface{}) *Entry {
+	return e.WithFields(Field{Key: key, Value: value})
+}
+
+// WithGroup returns a new Entry holding the entry's fields, where the fields
+// added later, including those passed at the call site of the logging
+// functions, are grouped under the given name, nested in the innermost group,
+// if any. Groups that end up with no fields are not rendered.
+func (e *Entry) WithGroup(name string) *Entry {
+	groups := make([]string, len(e.groups), len(e.groups)+1)
+	copy(groups, e.groups)
+	return &Entry{fields: e.fields, groups: append(groups, name)}
+}
+
+// merge returns a copy of the entry's fields with the given fields added to
+// the innermost group.
+func (e *Entry) merge(fields []Field) []Field {
+	return insert(e.fields, e.groups, fields)
+}
+
+// insert returns a copy of the tree of fields with the given fields appended
+// to the group at the given path, which is created if needed; since fields are
+// always added to the innermost open group, that is the last field at each
+// level, if it exists. Groups that are not on the path are shared.
+func insert(tree []Field, path []string, fields []Field) []Field {
+	if len(tree) == 0 && len(fields) == 0 {
+		return nil
+	}
+	result := make([]Field, len(tree), len(tree)+len(fields)+1)
+	copy(result, tree)
+	if len(fields) == 0 {
+		return result
+	}
+	if len(path) == 0 {
+		return append(result, fields...)
+	}
+	if n := len(result); n > 0 && result[n-1].Key == path[0] {
+		if group, ok := result[n-1].Value.([]Field); ok {
+			result[n-1].Value = insert(group, path[1:], fields)
+			return result
+		}
+	}
+	return append(result, Group(path[0], insert(nil, path[1:], fields)...))
+}
+
+// logf formats the message and writes it at the given level with the entry's
+// fields, exactly as the package's function for that level would; skip is the
+// number of stack frames to ascend from the caller of logf to reach the call
+// site.
+func (e *Entry) logf(level LogLevel, skip int, format string, args []interface{}) (int, error) {
+	if level != PanicLevel && (level < TraceLevel || level >= NoneLevel || !isEnabled(level) || discarded(level)) {
+		return 0, nil
+	}
+	message, fields := sprintf(format, args)
+	return e.output(level, skip+1, message, fields)
+}
+
+// logln formats the message as fmt.Sprintln does and writes it as logf does.
+func (e *Entry) logln(level LogLevel, skip int, args []interface{}) (int, error) {
+	if level != PanicLevel && (level < TraceLevel || level >= NoneLevel || !isEnabled(level) || discarded(level)) {
+		return 0, nil
+	}
+	message, fields := sprintln(args)
+	return e.output(level, skip+1, message, fields)
+}
+
+// output writes the message with the entry's fields, exactly as the package's
+// function for that level would.
+func (e *Entry) output(level LogLevel, skip int, message string, fields []Field) (int, error) {
+	return emit(time.Time{}, level, skip+1, nil, message, e.merge(fields))
+}
+
+// Traceln writes a trace message with the entry's fields to the current output
+// stream, appending a new line.
+func (e *Entry) Traceln(args ...interface{}) (int, error) {
+	return e.logln(TraceLevel, 1, args)
+}
+
+// Debugln writes a debug message with the entry's fields to the current output
+// stream, appending a new line.
+func (e *Entry) Debugln(args ...interface{}) (int, error) {
+	return e.logln(DebugLevel, 1, args)
+}
+
+// Infoln writes an informational message with the entry's fields to the current
+// output stream, appending a new line.
+func (e *Entry) Infoln(args ...interface{}) (int, error) {
+	return e.logln(InfoLevel, 1, args)
+}
+
+// Warnln writes a warning message with the entry's fields to the current output
+// stream, appending a new line.
+func (e *Entry) Warnln(args ...interface{}) (int, error) {
+	return e.logln(WarnLevel, 1, args)
+}
+
+// Errorln writes an error message with the entry's fields to the current output
+// stream, appending a new line.
+func (e *Entry) Errorln(args ...interface{}) (int, error) {
+	return e.logln(ErrorLevel, 1, args)
+}
+
+// Fatalln writes an error message with the entry's fields to the current output
+// stream, appending a new line.
+func (e *Entry) Fatalln(args ...interface{}) (int, error) {
+	return e.logln(FatalLevel, 1, args)
+}
+
+// Panicln writes an error message with the entry's fields to the current output
+// stream, appending a new line; then it panics (see SetPanicWithMessage for the
+// panic value).
+func (e *Entry) Panicln(args ...interface{}) (int, error) {
+	return e.logln(PanicLevel, 1, args)
+}
+
+// Tracef writes a trace message with the entry's fields to the current output
+// stream, appending a new line.
+func (e *Entry) Tracef(format string, args ...interface{}) (int, error) {
+	return e.logf(TraceLevel, 1, format, args)
+}
+
+// Debugf writes a debug message with the entry's fields to the current output
+// stream, appending a new line.
+func (e *Entry) Debugf(format string, args ...interface{}) (int, error) {
+	return e.logf(DebugLevel, 1, format, args)
+}
+
+// Infof writes an informational message with the entry's fields to the current
+// output stream, appending a new line.
+func (e *Entry) Infof(format string, args ...interface{}) (int, error) {
+	return e.logf(InfoLevel, 1, format, args)
+}
+
+// Warnf writes a warning message with the entry's fields to the current output
+// stream, appending a new line.
+func (e *Entry) Warnf(format string, args ...interface{}) (int, error) {
+	return e.logf(WarnLevel, 1, format, args)
+}
+
+// Errorf writes an error message with the entry's fields to the current output
+// stream, appending a new line.
+func (e *Entry) Errorf(format string, args ...interface{}) (int, error) {
+	return e.logf(ErrorLevel, 1, format, args)
+}
+
+// Fatalf writes an error message with the entry's fields to the current output
+// stream, appending a new line.
+func (e *Entry) Fatalf(format string, args ...interface{}) (int, error) {
+	return e.logf(FatalLevel, 1, format, args)
+}
+
+// Panicf writes an error message with the entry's fields to the current output
+// stream, appending a new line; then it panics (see SetPanicWithMessage for the
+// panic value).
+func (e *Entry) Panicf(format string, args ...interface{}) (int, error) {
+	return e.logf(PanicLevel, 1, format, args)
+}
+
+// Logf writes a message at the given level with the entry's fields to the
+// current output stream, appending a new line, exactly as the method for that
+// level would; messages at NoneLevel are discarded.
+func (e *Entry) Logf(level LogLevel, format string, args ...interface{}) (int, error) {
+	return e.logf(level, 1, format, args)
+}
+
+// Logln writes a message at the given level with the entry's fields to the
+// current output stream, appending a new line, exactly as the method for that
+// level would; messages at NoneLevel are discarded.
+func (e *Entry) Logln(level LogLevel, args ...interface{}) (int, error) {
+	return e.logln(level, 1, args)
+}
@@ -0,0 +1,76 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithGroup(t *testing.T) {
+	defer SetFormat(GetFormat())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+	SetPrintSourceInfo(SourceInfoNone)
+
+	base := WithFields(Str("service", "api"))
+	request := base.WithGroup("http").WithFields(Str("method", "GET"))
+
+	request.Infof("served", Int("status", 200))
+	if !strings.HasSuffix(buffer.String(), "served service=api http.method=GET http.status=200\n") {
+		t.Errorf("unexpected text output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetFormat(FormatLogfmt)
+	request.WithGroup("tls").Warnln("handshake", "slow", Str("version", "1.3"))
+	if !strings.HasSuffix(buffer.String(), `msg="handshake slow" service=api http.method=GET http.tls.version=1.3`+"\n") {
+		t.Errorf("unexpected logfmt output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	request.Errorf("failed", Int("status", 500))
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	expected := map[string]interface{}{"method": "GET", "status": float64(500)}
+	if !reflect.DeepEqual(entry["http"], expected) || entry["service"] != "api" {
+		t.Errorf("unexpected JSON output %q", buffer.String())
+	}
+
+	// entries are immutable and empty groups are not rendered
+	buffer.Reset()
+	SetFormat(FormatText)
+	base.WithGroup("empty").Infof("plain")
+	if !strings.HasSuffix(buffer.String(), "plain service=api\n") {
+		t.Errorf("unexpected text output %q", buffer.String())
+	}
+}
+
+func TestWith(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	base := With("user", "joe")
+	base.With("attempt", 3).Warnf("login failed")
+	base.Infoln("logged out")
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buffer.String())
+	}
+	if !strings.Contains(lines[0], "login failed user=joe attempt=3 (entry_test.go:") {
+		t.Errorf("unexpected line %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "logged out user=joe (entry_test.go:") {
+		t.Errorf("unexpected line %q", lines[1])
+	}
+}
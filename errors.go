@@ -0,0 +1,136 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WrapError wraps the error with the formatted message, logs the result at
+// error level and returns it, so that the error chain is preserved for
+// errors.Is and errors.As, e.g.
+//
+//	return log.WrapError(err, "failed to open %s", path)
+//
+// If err is nil, nothing is logged and nil is returned.
+func WrapError(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	message, fields := sprintf(format, args)
+	err = fmt.Errorf("%s: %w", message, err)
+	if IsError() {
+		output(ErrorLevel, 1, err.Error(), fields)
+	}
+	return err
+}
+
+var (
+	logPrintErrorChain     bool
+	logPrintErrorChainLock sync.RWMutex
+)
+
+// SetPrintErrorChain enables or disables the expansion of wrapped errors: when
+// enabled, each error passed as an argument to the logging functions that
+// wraps other errors, with an Unwrap() error or an Unwrap() []error method (as
+// errors.Join does), adds a field with the key set by SetErrorChainKey listing
+// the layers, outermost first and depth first, each with its own part of the
+// message and its type, e.g.
+//
+//	error_chain=open config (*fmt.wrapError) | read (*fs.PathError) | EOF (*errors.errorString)
+//
+// in text output, and an array of strings in JSON output. If more than one
+// such error is passed, the keys of the fields after the first are suffixed
+// with their ordinal, e.g. "error_chain_2".
+func SetPrintErrorChain(enabled bool) {
+	defer changed()
+	logPrintErrorChainLock.Lock()
+	defer logPrintErrorChainLock.Unlock()
+	logPrintErrorChain = enabled
+}
+
+// GetPrintErrorChain returns whether wrapped errors are expanded.
+func GetPrintErrorChain() bool {
+	logPrintErrorChainLock.RLock()
+	defer logPrintErrorChainLock.RUnlock()
+	return logPrintErrorChain
+}
+
+// errorChain holds the layers of a wrapped error, outermost first.
+type errorChain []string
+
+// String returns the layers separated by vertical bars.
+func (c errorChain) String() string {
+	return strings.Join(c, " | ")
+}
+
+// errorChains returns a field for each error among the arguments that wraps
+// other errors, if wrapped errors are expanded.
+func errorChains(args []interface{}) []Field {
+	s := current()
+	if !s.printErrorChain {
+		return nil
+	}
+	var fields []Field
+	for _, arg := range args {
+		err, ok := arg.(error)
+		if !ok || len(unwrap(err)) == 0 {
+			continue
+		}
+		chain := appendLayers(nil, err)
+		key := s.errorChainKey
+		if len(fields) > 0 {
+			key += "_" + strconv.Itoa(len(fields)+1)
+		}
+		fields = append(fields, Field{Key: key, Value: chain})
+	}
+	return fields
+}
+
+// appendLayers appends the layers of the error to the chain, depth first, each
+// with its own part of the message, if any, and its type.
+func appendLayers(chain errorChain, err error) errorChain {
+	inner := unwrap(err)
+	message := err.Error()
+	switch len(inner) {
+	case 0:
+	case 1:
+		message = strings.TrimSuffix(message, ": "+inner[0].Error())
+	default:
+		// errors.Join only joins the messages of the errors it wraps
+		messages := make([]string, len(inner))
+		for i, err := range inner {
+			messages[i] = err.Error()
+		}
+		message = strings.TrimSuffix(message, strings.Join(messages, "\n"))
+	}
+	if message == "" {
+		chain = append(chain, fmt.Sprintf("(%T)", err))
+	} else {
+		chain = append(chain, fmt.Sprintf("%s (%T)", message, err))
+	}
+	for _, err := range inner {
+		chain = appendLayers(chain, err)
+	}
+	return chain
+}
+
+// unwrap returns the errors wrapped by the error, whether it has an Unwrap()
+// error method, as errors wrapped with %w do, or an Unwrap() []error method, as
+// errors.Join and errors wrapped with several %w do.
+func unwrap(err error) []error {
+	switch err := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := err.Unwrap(); inner != nil {
+			return []error{inner}
+		}
+	case interface{ Unwrap() []error }:
+		return err.Unwrap()
+	}
+	return nil
+}
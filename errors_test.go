@@ -0,0 +1,101 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWrapError(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	err := WrapError(fs.ErrNotExist, "failed to open %s", "config.yaml")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected error chain to be preserved, got %v", err)
+	}
+	if err.Error() != "failed to open config.yaml: file does not exist" {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+	re := regexp.MustCompile(`^\[E\] .* go-log\.TestWrapError: failed to open config\.yaml: file does not exist \(errors_test\.go:\d+\)\n$`)
+	if !re.MatchString(buffer.String()) {
+		t.Errorf("unexpected log line %q", buffer.String())
+	}
+
+	buffer.Reset()
+	if err := WrapError(nil, "nothing to wrap"); err != nil || buffer.Len() != 0 {
+		t.Errorf("expected nil error and no output, got %v and %q", err, buffer.String())
+	}
+}
+
+func TestPrintErrorChain(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+	defer SetFormat(GetFormat())
+	defer SetPrintErrorChain(GetPrintErrorChain())
+
+	err := fmt.Errorf("open config: %w", &fs.PathError{Op: "read", Path: "config.yaml", Err: io.EOF})
+	Errorln("cannot start:", err)
+	if strings.Contains(buffer.String(), GetErrorChainKey()) {
+		t.Errorf("unexpected error chain in %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetPrintErrorChain(true)
+	Errorf("cannot start: %v", err)
+	expected := " error_chain=open config (*fmt.wrapError) | read config.yaml (*fs.PathError) | EOF (*errors.errorString)"
+	if !strings.Contains(buffer.String(), expected) {
+		t.Errorf("expected %q in %q", expected, buffer.String())
+	}
+
+	buffer.Reset()
+	Errorln("not wrapped:", io.EOF)
+	if strings.Contains(buffer.String(), GetErrorChainKey()) {
+		t.Errorf("unexpected error chain in %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	Errorln("cannot start:", err)
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	if chain, ok := entry[GetErrorChainKey()].([]interface{}); !ok || len(chain) != 3 || chain[1] != "read config.yaml (*fs.PathError)" {
+		t.Errorf("unexpected JSON line %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetFormat(FormatText)
+	Errorf("%v", errors.Join(err, fmt.Errorf("close: %w", io.ErrClosedPipe)))
+	expected = " error_chain=(*errors.joinError) | open config (*fmt.wrapError) | read config.yaml (*fs.PathError) | EOF (*errors.errorString) | close (*fmt.wrapError) | io: read/write on closed pipe (*errors.errorString)"
+	if !strings.Contains(buffer.String(), expected) {
+		t.Errorf("expected %q in %q", expected, buffer.String())
+	}
+
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	defer SetErrorChainKey(GetErrorChainKey())
+	SetErrorChainKey("chain")
+	Errorln("cannot start:", err, fmt.Errorf("retry: %w", io.EOF))
+	entry = map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	if _, ok := entry["chain"].([]interface{}); !ok {
+		t.Errorf("expected the first error chain under the custom key in %q", buffer.String())
+	}
+	if chain, ok := entry["chain_2"].([]interface{}); !ok || len(chain) != 2 || chain[0] != "retry (*fmt.wrapError)" {
+		t.Errorf("expected the second error chain under a suffixed key in %q", buffer.String())
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package log
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventID is the event ID of the entries written to the Windows Event Log.
+const eventID = 1
+
+// NewEventLogWriter returns a writer that sends messages to the Windows Event
+// Log under the given source, which must have been registered (e.g. with
+// eventlog.InstallAsEventCreate); when it is set as the stream, the level of
+// each message is mapped to the event type: warnings to Warning, errors, fatal
+// and panic messages to Error, and the rest to Information. The writer must be
+// closed when no longer needed.
+func NewEventLogWriter(source string) (io.WriteCloser, error) {
+	log, err := eventlog.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLogWriter{log: log}, nil
+}
+
+// eventLogWriter is the io.WriteCloser returned by NewEventLogWriter.
+type eventLogWriter struct {
+	log *eventlog.Log
+}
+
+// Write sends p to the event log as an Information event.
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(InfoLevel, p)
+}
+
+// WriteLevel sends p to the event log with the event type corresponding to
+// the level.
+func (w *eventLogWriter) WriteLevel(level LogLevel, p []byte) (int, error) {
+	message := strings.TrimRight(string(p), "\r\n")
+	var err error
+	switch level {
+	case WarnLevel:
+		err = w.log.Warning(eventID, message)
+	case ErrorLevel, FatalLevel, PanicLevel:
+		err = w.log.Error(eventID, message)
+	default:
+		err = w.log.Info(eventID, message)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the handle to the event log.
+func (w *eventLogWriter) Close() error {
+	return w.log.Close()
+}
@@ -0,0 +1,71 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io"
+	"sync"
+)
+
+var (
+	logFallbackStream     io.Writer
+	logFallbackStreamLock sync.RWMutex
+	logWriteErrorHandler  func(error)
+	logWriteErrorLock     sync.RWMutex
+)
+
+// SetFallbackStream sets a stream, e.g. os.Stderr, to which messages are
+// written, uncoloured, when writing them to the current stream fails, e.g.
+// because of a broken pipe or a full disk, so that they are not lost; the
+// returned byte count and error are then those of the fallback stream. If nil,
+// which is the default, failed messages are lost.
+func SetFallbackStream(stream io.Writer) {
+	logFallbackStreamLock.Lock()
+	defer logFallbackStreamLock.Unlock()
+	logFallbackStream = stream
+}
+
+// GetFallbackStream returns the stream to which messages are written when
+// writing them to the current stream fails, or nil if there is none.
+func GetFallbackStream() io.Writer {
+	logFallbackStreamLock.RLock()
+	defer logFallbackStreamLock.RUnlock()
+	return logFallbackStream
+}
+
+// SetWriteErrorHandler sets a function that is called with the error whenever
+// writing a message to the current stream fails, before falling back to the
+// fallback stream, if any; it allows to observe and react to such failures,
+// e.g. by reopening the stream, but it must not log through this package.
+func SetWriteErrorHandler(handler func(error)) {
+	logWriteErrorLock.Lock()
+	defer logWriteErrorLock.Unlock()
+	logWriteErrorHandler = handler
+}
+
+// GetWriteErrorHandler returns the function that is called when writing a
+// message to the current stream fails, or nil if there is none.
+func GetWriteErrorHandler() func(error) {
+	logWriteErrorLock.RLock()
+	defer logWriteErrorLock.RUnlock()
+	return logWriteErrorHandler
+}
+
+// writeFailed handles the failure to write the record to the current stream,
+// notifying the error handler and writing the record to the fallback stream,
+// if any; it returns the result of the latter, or the original error.
+func writeFailed(r *Record, n int, err error) (int, error) {
+	if handler := GetWriteErrorHandler(); handler != nil {
+		handler(err)
+	}
+	fallback := GetFallbackStream()
+	if fallback == nil {
+		return n, err
+	}
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	render(buffer, r)
+	return fallback.Write(buffer.Bytes())
+}
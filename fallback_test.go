@@ -0,0 +1,49 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// brokenWriter fails every write.
+type brokenWriter struct{}
+
+func (brokenWriter) Write(p []byte) (int, error) {
+	return 0, syscall.EPIPE
+}
+
+func TestFallbackStream(t *testing.T) {
+	defer SetFallbackStream(GetFallbackStream())
+	defer SetWriteErrorHandler(GetWriteErrorHandler())
+	defer SetForceColorise(GetForceColorise())
+	defer WithWriter(brokenWriter{}, true)()
+
+	SetForceColorise(true)
+	var failures []error
+	SetWriteErrorHandler(func(err error) {
+		failures = append(failures, err)
+	})
+	if _, err := Errorln("lost"); !errors.Is(err, syscall.EPIPE) {
+		t.Errorf("expected broken pipe, got %v", err)
+	}
+
+	fallback := &bytes.Buffer{}
+	SetFallbackStream(fallback)
+	n, err := Errorln("rescued")
+	if err != nil || n != fallback.Len() {
+		t.Errorf("expected %d bytes written to the fallback, got %d (%v)", fallback.Len(), n, err)
+	}
+	if !strings.HasPrefix(fallback.String(), "[E] ") || !strings.Contains(fallback.String(), "rescued") {
+		t.Errorf("unexpected fallback output %q", fallback.String())
+	}
+	if len(failures) != 2 {
+		t.Errorf("expected 2 failures, got %d", len(failures))
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Field is a key/value pair that can be passed along with the other arguments
+// to the logging functions; it renders as key=value.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns the key=value representation of the field; a group renders as
+// the key=value representations of its fields, separated by spaces, with their
+// keys prefixed by the name of the group and a dot (e.g. http.method=GET).
+func (f Field) String() string {
+	if _, ok := f.Value.([]Field); ok {
+		flat := flatten([]Field{f})
+		parts := make([]string, len(flat))
+		for i, field := range flat {
+			parts[i] = field.String()
+		}
+		return strings.Join(parts, " ")
+	}
+	return f.Key + "=" + formatValue(f.Value)
+}
+
+// Fields is a set of key/value pairs; when passed as the last argument to any
+// of the logging functions, e.g.
+//
+//	log.Infof("done", log.Fields{"took": d})
+//
+// it is not used to format the message but is rendered after it, as key=value
+// pairs in text and logfmt output or as additional keys in JSON output. The
+// same applies to one or more trailing Field arguments.
+type Fields map[string]interface{}
+
+// sorted returns the fields as a slice, sorted by key.
+func (f Fields) sorted() []Field {
+	fields := make([]Field, 0, len(f))
+	for key, value := range f {
+		fields = append(fields, Field{Key: key, Value: value})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Key < fields[j].Key
+	})
+	return fields
+}
+
+// splitFields separates the trailing Field and Fields arguments from the
+// others.
+func splitFields(args []interface{}) ([]interface{}, []Field) {
+	i := len(args)
+	for i > 0 {
+		switch args[i-1].(type) {
+		case Field, Fields:
+			i--
+			continue
+		}
+		break
+	}
+	if i == len(args) {
+		return args, nil
+	}
+	fields := []Field{}
+	for _, arg := range args[i:] {
+		switch arg := arg.(type) {
+		case Field:
+			fields = append(fields, arg)
+		case Fields:
+			fields = append(fields, arg.sorted()...)
+		}
+	}
+	return args[:i], fields
+}
+
+var (
+	logDefaultFields     []Field
+	logDefaultFieldsLock sync.RWMutex
+)
+
+// SetDefaultFields sets fields that are added to every message, e.g. the name
+// and version of the service, so that they need not be passed at each call
+// site; they are rendered before the other fields, sorted by key, and a field
+// with the same key passed at the call site or held by an Entry replaces the
+// default one. Passing nil or empty fields, which is the default, removes them.
+func SetDefaultFields(fields Fields) {
+	defer changed()
+	logDefaultFieldsLock.Lock()
+	defer logDefaultFieldsLock.Unlock()
+	logDefaultFields = nil
+	if len(fields) > 0 {
+		logDefaultFields = fields.sorted()
+	}
+}
+
+// GetDefaultFields returns a copy of the fields that are added to every
+// message, or nil if there are none.
+func GetDefaultFields() Fields {
+	logDefaultFieldsLock.RLock()
+	defer logDefaultFieldsLock.RUnlock()
+	if logDefaultFields == nil {
+		return nil
+	}
+	fields := Fields{}
+	for _, field := range logDefaultFields {
+		fields[field.Key] = field.Value
+	}
+	return fields
+}
+
+// getDefaultFields returns the fields that are added to every message, sorted
+// by key; the slice must not be modified.
+func getDefaultFields() []Field {
+	logDefaultFieldsLock.RLock()
+	defer logDefaultFieldsLock.RUnlock()
+	return logDefaultFields
+}
+
+// withDefaults returns the default fields that are not replaced by any of the
+// given fields, followed by the given fields.
+func withDefaults(defaults []Field, fields []Field) []Field {
+	if len(defaults) == 0 {
+		return fields
+	}
+	result := make([]Field, 0, len(defaults)+len(fields))
+	for _, d := range defaults {
+		if !hasKey(fields, d.Key) {
+			result = append(result, d)
+		}
+	}
+	return append(result, fields...)
+}
+
+// hasKey returns whether any of the fields has the given key.
+func hasKey(fields []Field, key string) bool {
+	for _, field := range fields {
+		if field.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Str returns a Field holding a string.
+func Str(key string, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int returns a Field holding an integer; the value keeps its native type so
+// that structured output formats can render it as a number.
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Float returns a Field holding a floating point number; the value keeps its
+// native type so that structured output formats can render it as a number.
+func Float(key string, value float64) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Bool returns a Field holding a boolean; the value keeps its native type so
+// that structured output formats can render it unquoted.
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Dur returns a Field holding a duration; durations are always rendered in
+// milliseconds (e.g. latency=12.3ms) so that they can be easily parsed and
+// aggregated, unlike the 1h2m3s form produced by time.Duration.String().
+func Dur(key string, d time.Duration) Field {
+	return Field{Key: key, Value: d}
+}
+
+// Group returns a Field holding a group of fields, i.e. a []Field; groups are
+// rendered with their name as a prefix to the keys of their fields in text and
+// logfmt output (e.g. http.method=GET) and as nested objects in JSON output.
+func Group(key string, fields ...Field) Field {
+	return Field{Key: key, Value: fields}
+}
+
+// flatten returns the fields with the groups replaced by their fields, whose
+// keys are prefixed with the name of the group and a dot; the fields are
+// returned as they are if there are no groups.
+func flatten(fields []Field) []Field {
+	for _, field := range fields {
+		if _, ok := field.Value.([]Field); ok {
+			return appendFlat(make([]Field, 0, len(fields)), "", fields)
+		}
+	}
+	return fields
+}
+
+// appendFlat appends the flattened fields to the slice, prefixing their keys.
+func appendFlat(flat []Field, prefix string, fields []Field) []Field {
+	for _, field := range fields {
+		if group, ok := field.Value.([]Field); ok {
+			flat = appendFlat(flat, prefix+field.Key+".", group)
+		} else {
+			flat = append(flat, Field{Key: prefix + field.Key, Value: field.Value})
+		}
+	}
+	return flat
+}
+
+// formatValue renders a field value in text form.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Duration:
+		return strconv.FormatFloat(float64(v)/float64(time.Millisecond), 'f', -1, 64) + "ms"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDur(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{12300 * time.Microsecond, "latency=12.3ms"},
+		{2 * time.Second, "latency=2000ms"},
+		{time.Hour + 2*time.Minute, "latency=3720000ms"},
+		{0, "latency=0ms"},
+	}
+	for _, test := range tests {
+		if actual := Dur("latency", test.duration).String(); actual != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestTypedFields(t *testing.T) {
+	tests := []struct {
+		field    Field
+		value    interface{}
+		expected string
+	}{
+		{Str("user", "admin"), "admin", "user=admin"},
+		{Int("count", 42), 42, "count=42"},
+		{Float("ratio", 0.25), 0.25, "ratio=0.25"},
+		{Bool("ok", true), true, "ok=true"},
+	}
+	for _, test := range tests {
+		if test.field.Value != test.value {
+			t.Errorf("expected native value %v (%T), got %v (%T)", test.value, test.value, test.field.Value, test.field.Value)
+		}
+		if actual := test.field.String(); actual != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, actual)
+		}
+	}
+}
+
+func TestTrailingFields(t *testing.T) {
+	defer SetFormat(GetFormat())
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+	SetPrintCallerInfo(false)
+	SetPrintSourceInfo(SourceInfoShort)
+
+	Infof("copied %d files", 3, Fields{"user": "admin", "dry": false}, Dur("took", 1500*time.Microsecond))
+	if !regexp.MustCompile(` - copied 3 files dry=false user=admin took=1\.5ms \(fields_test\.go:\d+\)\n$`).MatchString(buffer.String()) {
+		t.Errorf("unexpected text line %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	Infoln("copied", "files\n", Int("count", 3), Bool("dry", false))
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	if entry["msg"] != "copied files" || entry["count"] != 3.0 || entry["dry"] != false {
+		t.Errorf("unexpected JSON line %q", buffer.String())
+	}
+}
+
+func TestSetDefaultFields(t *testing.T) {
+	defer SetFormat(GetFormat())
+	defer SetDefaultFields(GetDefaultFields())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetFormat(FormatLogfmt)
+	SetDefaultFields(Fields{"version": "1.2.3", "service": "checkout"})
+	if fields := GetDefaultFields(); len(fields) != 2 || fields["service"] != "checkout" {
+		t.Errorf("unexpected default fields %v", fields)
+	}
+	Infof("served", Int("status", 200))
+	if !strings.HasSuffix(buffer.String(), " msg=served service=checkout version=1.2.3 status=200\n") {
+		t.Errorf("unexpected line %q", buffer.String())
+	}
+
+	buffer.Reset()
+	With("version", "2.0.0").Infof("served")
+	if !strings.HasSuffix(buffer.String(), " msg=served service=checkout version=2.0.0\n") {
+		t.Errorf("expected default field to be replaced, got %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetDefaultFields(nil)
+	Infof("served")
+	if GetDefaultFields() != nil || !strings.HasSuffix(buffer.String(), " msg=served\n") {
+		t.Errorf("expected no default fields, got %q", buffer.String())
+	}
+}
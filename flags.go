@@ -0,0 +1,203 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RegisterFlags registers on the given flag set the flags that configure the
+// logger, which are applied through the corresponding setters as they are
+// parsed:
+//
+//	-log-level        the log level, as parsed by LevelFromString
+//	-log-color        whether the current stream is colourised (see SetStream)
+//	-log-caller       whether the caller info is printed (see SetPrintCallerInfo)
+//	-log-source       "none", "short" or "long" (see SetPrintSourceInfoByName)
+//	-log-time-format  a time format name or layout (see SetTimeFormatNamed)
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.Var(levelFlag{}, "log-level", "the log level (trace, debug, info, warning, error, fatal, panic or none)")
+	fs.Var(colorFlag{}, "log-color", "whether to colourise the log output on terminals")
+	fs.Var(callerFlag{}, "log-caller", "whether to print the name of the calling function")
+	fs.Var(sourceFlag{}, "log-source", "whether to print the source file and line (none, short or long)")
+	fs.Var(timeFormatFlag{}, "log-time-format", "the time format, either a name (e.g. rfc3339) or a layout")
+}
+
+// configValue is a flag.Value whose value can be validated without being
+// applied, so that ConfigFromString can apply all settings or none.
+type configValue interface {
+	flag.Value
+	// parse validates the value and returns the function that applies it.
+	parse(value string) (func(), error)
+}
+
+// configKeys maps the keys accepted by ConfigFromString to the flag.Values
+// that apply them.
+var configKeys = map[string]configValue{
+	"level":  levelFlag{},
+	"color":  colorFlag{},
+	"caller": callerFlag{},
+	"source": sourceFlag{},
+	"time":   timeFormatFlag{},
+}
+
+// ConfigFromString applies a compact, comma-separated list of key=value
+// settings, e.g. from a single environment variable:
+//
+//	level=debug,color=true,caller=false,source=short,time=15:04:05
+//
+// The keys and values are those of the flags registered by RegisterFlags,
+// without the "log-" prefix, and settings are applied in order through the
+// same setters. Every entry is validated before any setting is applied, so
+// that a malformed entry, an unknown key or an invalid value leaves all the
+// settings unchanged.
+func ConfigFromString(spec string) error {
+	var apply []func()
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid log setting %q: expected key=value", item)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		setting, ok := configKeys[key]
+		if !ok {
+			return fmt.Errorf("unknown log setting %q", key)
+		}
+		value = strings.TrimSpace(value)
+		set, err := setting.parse(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for log setting %q: %w", value, key, err)
+		}
+		apply = append(apply, set)
+	}
+	for _, set := range apply {
+		set()
+	}
+	return nil
+}
+
+// levelFlag is the flag.Value for the log level.
+type levelFlag struct{}
+
+func (levelFlag) String() string {
+	return GetLevel().name()
+}
+
+func (f levelFlag) Set(value string) error {
+	return set(f, value)
+}
+
+func (levelFlag) parse(value string) (func(), error) {
+	level, err := LevelFromString(value)
+	if err != nil {
+		return nil, err
+	}
+	return func() { SetLevel(level) }, nil
+}
+
+// colorFlag is the flag.Value for colourising the current stream.
+type colorFlag struct{}
+
+func (colorFlag) String() string {
+	return strconv.FormatBool(logStream.Load().colorise)
+}
+
+func (f colorFlag) Set(value string) error {
+	return set(f, value)
+}
+
+func (colorFlag) parse(value string) (func(), error) {
+	colorise, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		updateStream(func(*streamState) bool {
+			return colorise
+		})
+	}, nil
+}
+
+func (colorFlag) IsBoolFlag() bool {
+	return true
+}
+
+// callerFlag is the flag.Value for printing the caller info.
+type callerFlag struct{}
+
+func (callerFlag) String() string {
+	return strconv.FormatBool(GetPrintCallerInfo())
+}
+
+func (f callerFlag) Set(value string) error {
+	return set(f, value)
+}
+
+func (callerFlag) parse(value string) (func(), error) {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return func() { SetPrintCallerInfo(enabled) }, nil
+}
+
+func (callerFlag) IsBoolFlag() bool {
+	return true
+}
+
+// sourceFlag is the flag.Value for printing the source info.
+type sourceFlag struct{}
+
+func (sourceFlag) String() string {
+	return sourceInfoName(GetPrintSourceInfo())
+}
+
+func (f sourceFlag) Set(value string) error {
+	return set(f, value)
+}
+
+func (sourceFlag) parse(value string) (func(), error) {
+	source, err := sourceInfoFromName(value)
+	if err != nil {
+		return nil, err
+	}
+	return func() { SetPrintSourceInfo(source) }, nil
+}
+
+// timeFormatFlag is the flag.Value for the time format; names of well-known
+// formats take precedence over layouts.
+type timeFormatFlag struct{}
+
+func (timeFormatFlag) String() string {
+	return GetTimeFormat()
+}
+
+func (f timeFormatFlag) Set(value string) error {
+	return set(f, value)
+}
+
+func (timeFormatFlag) parse(value string) (func(), error) {
+	return func() {
+		if SetTimeFormatNamed(value) != nil {
+			SetTimeFormat(value)
+		}
+	}, nil
+}
+
+// set validates and applies the value of a flag.
+func set(v configValue, value string) error {
+	apply, err := v.parse(value)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
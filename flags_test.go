@@ -0,0 +1,79 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterFlags(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	defer SetTimeFormat(GetTimeFormat())
+	defer WithWriter(&bytes.Buffer{}, true)()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	RegisterFlags(fs)
+	err := fs.Parse([]string{
+		"-log-level", "warning",
+		"-log-color=false",
+		"-log-caller=false",
+		"-log-source", "long",
+		"-log-time-format", "rfc3339",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if GetLevel() != WarnLevel || GetPrintCallerInfo() || GetPrintSourceInfo() != SourceInfoLong || GetTimeFormat() != time.RFC3339 {
+		t.Errorf("unexpected settings after parsing flags")
+	}
+	if fs.Lookup("log-color").Value.String() != "false" {
+		t.Errorf("expected colours to be disabled")
+	}
+
+	if err := fs.Parse([]string{"-log-time-format", "15:04"}); err != nil || GetTimeFormat() != "15:04" {
+		t.Errorf("expected layout to be accepted, got %q (%v)", GetTimeFormat(), err)
+	}
+	if err := fs.Parse([]string{"-log-level", "loud"}); err == nil || !strings.Contains(err.Error(), "log-level") {
+		t.Errorf("expected error for invalid level, got %v", err)
+	}
+}
+
+func TestConfigFromString(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	defer SetTimeFormat(GetTimeFormat())
+	defer WithWriter(&bytes.Buffer{}, false)()
+
+	if err := ConfigFromString("level=warning, caller=false,source=short,time=15:04:05,color=true"); err != nil {
+		t.Fatal(err)
+	}
+	if GetLevel() != WarnLevel || GetPrintCallerInfo() || GetPrintSourceInfo() != SourceInfoShort || GetTimeFormat() != "15:04:05" {
+		t.Errorf("unexpected settings after parsing config")
+	}
+
+	for spec, expected := range map[string]string{
+		"level=error,verbose=true": `unknown log setting "verbose"`,
+		"level":                    "expected key=value",
+		"level=loud":               `invalid value "loud" for log setting "level"`,
+		"level=error,color=maybe":  `invalid value "maybe" for log setting "color"`,
+		"level=error,source=wide":  `invalid value "wide" for log setting "source"`,
+	} {
+		if err := ConfigFromString(spec); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q for %q, got %v", expected, spec, err)
+		}
+	}
+	if GetLevel() != WarnLevel {
+		t.Errorf("expected invalid config not to be applied, got level %v", GetLevel())
+	}
+}
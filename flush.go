@@ -0,0 +1,111 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+var (
+	logFlushOnLevel     LogLevel
+	logFlushOnLevelLock sync.RWMutex
+)
+
+func init() {
+	SetFlushOnLevel(NoneLevel)
+}
+
+// flusher is implemented by streams that buffer data, such as *bufio.Writer
+// and *gzip.Writer.
+type flusher interface {
+	Flush() error
+}
+
+// Flush writes the records held by the formatter (see BatchFormatter) and the
+// lines batched by the logger (see SetWriteBatch), if any, and any data
+// buffered by the current stream to its destination, if the stream supports it
+// (i.e. it has a Flush() error method).
+func Flush() error {
+	if err := flushFormatter(); err != nil {
+		return err
+	}
+	if err := flushBatch(); err != nil {
+		return err
+	}
+	stream := logStream.Load().raw
+	if f, ok := stream.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// FlushContext is like Flush, but it checks the context before each of its
+// steps (the formatter, the batched lines and the stream), so that a graceful
+// shutdown can give up on a flush that is taking too long; if the context is
+// cancelled or times out, it returns an error wrapping the context's error and
+// the number of bytes, not messages, still waiting to be written, i.e. those of
+// the batched lines plus those buffered by the stream, if it can tell (i.e. it
+// has a Buffered() int method, as *bufio.Writer does). The steps themselves are
+// run synchronously, since the stream is not safe for concurrent use while it
+// is flushed and logging may carry on as soon as FlushContext returns, so a
+// stream that blocks in its Flush method blocks FlushContext as well.
+func FlushContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return cancelled(err)
+	}
+	if err := flushFormatter(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return cancelled(err)
+	}
+	if err := flushBatch(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return cancelled(err)
+	}
+	stream := logStream.Load().raw
+	if f, ok := stream.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// cancelled returns the error of a cancelled flush, with the number of bytes
+// still waiting to be written, if known.
+func cancelled(err error) error {
+	logBatch.lock.Lock()
+	pending := len(logBatch.pending)
+	logBatch.lock.Unlock()
+	if b, ok := logStream.Load().raw.(interface{ Buffered() int }); ok {
+		pending += b.Buffered()
+	} else if pending == 0 {
+		return fmt.Errorf("flush cancelled: %w", err)
+	}
+	return fmt.Errorf("flush cancelled with %d bytes remaining: %w", pending, err)
+}
+
+// SetFlushOnLevel sets the minimum level of the messages after which the
+// stream is flushed synchronously (see Flush), e.g. ErrorLevel to make sure
+// that critical messages are not lost in a buffered writer if the process
+// then crashes, while keeping the throughput of buffering for the others.
+// NoneLevel, which is the default, disables it.
+func SetFlushOnLevel(level LogLevel) {
+	defer changed()
+	logFlushOnLevelLock.Lock()
+	defer logFlushOnLevelLock.Unlock()
+	logFlushOnLevel = level
+}
+
+// GetFlushOnLevel returns the minimum level of the messages after which the
+// stream is flushed.
+func GetFlushOnLevel() LogLevel {
+	logFlushOnLevelLock.RLock()
+	defer logFlushOnLevelLock.RUnlock()
+	return logFlushOnLevel
+}
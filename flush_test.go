@@ -0,0 +1,79 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFlush(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer := bufio.NewWriter(buffer)
+	defer WithWriter(writer, false)()
+
+	Infoln("buffered message")
+	if buffer.Len() != 0 {
+		t.Fatalf("expected message to be buffered, got %q", buffer.String())
+	}
+	if err := FlushContext(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buffer.String(), "buffered message") {
+		t.Errorf("expected message to be flushed, got %q", buffer.String())
+	}
+}
+
+func TestFlushContextCancelled(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer := bufio.NewWriter(buffer)
+	defer WithWriter(writer, false)()
+
+	Infoln("pending message")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := FlushContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context cancelled, got %v", err)
+	}
+	expected := fmt.Sprintf("%d bytes remaining", writer.Buffered())
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected %q in %q", expected, err.Error())
+	}
+	if buffer.Len() != 0 {
+		t.Errorf("expected nothing to be flushed, got %q", buffer.String())
+	}
+
+	// logging right after a cancelled flush must not race with it
+	Infoln("another message")
+	if err := FlushContext(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buffer.String(), "pending message") || !strings.Contains(buffer.String(), "another message") {
+		t.Errorf("expected both messages to be flushed, got %q", buffer.String())
+	}
+}
+
+func TestFlushOnLevel(t *testing.T) {
+	defer SetFlushOnLevel(GetFlushOnLevel())
+	buffer := &bytes.Buffer{}
+	writer := bufio.NewWriter(buffer)
+	defer WithWriter(writer, false)()
+
+	SetFlushOnLevel(ErrorLevel)
+	Warnln("buffered warning")
+	if buffer.Len() != 0 {
+		t.Fatalf("expected warning to be buffered, got %q", buffer.String())
+	}
+	Errorln("critical error")
+	if !strings.Contains(buffer.String(), "buffered warning") || !strings.Contains(buffer.String(), "critical error") {
+		t.Errorf("expected messages to be flushed, got %q", buffer.String())
+	}
+}
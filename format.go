@@ -0,0 +1,921 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// LogFormat represents the format of the log messages.
+type LogFormat int8
+
+const (
+	// FormatText is the LogFormat for human readable messages, such as
+	// "[I] 2006-01-02@15:04:05.000 - pkg.Func: message (file.go:42)".
+	FormatText LogFormat = iota
+	// FormatJSON is the LogFormat for messages written as one JSON object per
+	// line (JSON lines); newlines in messages, fields and values produced by
+	// json.Marshaler implementations are always escaped, so that each record
+	// occupies exactly one physical line, as log shippers tailing files expect.
+	FormatJSON
+	// FormatLogfmt is the LogFormat for messages written as a sequence of
+	// key=value pairs.
+	FormatLogfmt
+	// FormatGELF is the LogFormat for messages written as GELF 1.1 JSON objects,
+	// one per line, as ingested by Graylog; log levels are mapped to syslog
+	// severities and fields to additional fields, prefixed with an underscore.
+	FormatGELF
+	// FormatJournal is the LogFormat for human readable messages, as with
+	// FormatText, where each line is prefixed with the syslog priority of the
+	// level, e.g. "<3>" for errors, so that the systemd journal can assign the
+	// severity to the messages of a service writing to its standard output or
+	// error, and "journalctl -p err" can filter them.
+	FormatJournal
+)
+
+// LevelFormat represents the way the level of log messages is rendered.
+type LevelFormat int8
+
+const (
+	// LevelLetter is the LevelFormat for levels rendered as a letter in text
+	// output, surrounded as set with SetLevelTagStyle, e.g. "[D]", and as a
+	// word in structured output.
+	LevelLetter LevelFormat = iota
+	// LevelWord is the LevelFormat for levels rendered as a lowercase word,
+	// e.g. "debug", in all formats; in text output, words are padded with
+	// spaces to the length of the longest one, so that columns line up.
+	LevelWord
+	// LevelSyslogNumber is the LevelFormat for levels rendered as the number
+	// of the corresponding syslog severity, from 0 (emergency) to 7 (debug),
+	// e.g. "7" for debug messages and "3" for errors, in all formats; in JSON
+	// output it is a number.
+	LevelSyslogNumber
+)
+
+// LineEnding represents the sequence terminating the lines of log messages.
+type LineEnding int8
+
+const (
+	// LineLF is the LineEnding for lines terminated by a line feed, as usual
+	// on Unix systems.
+	LineLF LineEnding = iota
+	// LineCRLF is the LineEnding for lines terminated by a carriage return and
+	// a line feed, as expected by some tools on Windows.
+	LineCRLF
+)
+
+var (
+	logFormat        LogFormat
+	logFormatLock    sync.RWMutex
+	logTagLeft       string
+	logTagRight      string
+	logTagStyleLock  sync.RWMutex
+	logLevelFormat   LevelFormat
+	logLevelFmtLock  sync.RWMutex
+	logIndent        bool
+	logIndentLock    sync.RWMutex
+	logLineEnding    LineEnding
+	logLineEndLock   sync.RWMutex
+	logPrefix        string
+	logPrefixLock    sync.RWMutex
+	logPrintLevel    bool
+	logPrintLvlLock  sync.RWMutex
+	logMessageKey    string
+	logLevelKey      string
+	logTimeKey       string
+	logCallerKey     string
+	logSourceKey     string
+	logGoroutineKey  string
+	logStackKey      string
+	logSequenceKey   string
+	logHostKey       string
+	logErrorChainKey string
+	logKeysLock      sync.RWMutex
+)
+
+func init() {
+	SetFormat(FormatText)
+	SetLevelTagStyle("[", "]")
+	SetPrintLevel(true)
+	SetMessageKey("msg")
+	SetLevelKey("level")
+	SetTimeKey("time")
+	SetCallerKey("caller")
+	SetSourceKey("source")
+	SetGoroutineKey("goroutine")
+	SetStackKey("stack")
+	SetSequenceKey("seq")
+	SetHostKey("host")
+	SetErrorChainKey("error_chain")
+}
+
+// SetFormat sets the format of log messages.
+func SetFormat(format LogFormat) {
+	defer changed()
+	logFormatLock.Lock()
+	defer logFormatLock.Unlock()
+	logFormat = format
+}
+
+// GetFormat returns the current format of log messages.
+func GetFormat() LogFormat {
+	logFormatLock.RLock()
+	defer logFormatLock.RUnlock()
+	return logFormat
+}
+
+// SetLevelTagStyle sets the characters surrounding the level letter in text
+// output, e.g. "<" and ">" for "<D>", "" and "|" for "D|", or empty strings for
+// no brackets at all; it defaults to "[" and "]". Println and Printf keep
+// recognising the canonical "[D]" form only, regardless of the style.
+func SetLevelTagStyle(left, right string) {
+	defer changed()
+	logTagStyleLock.Lock()
+	defer logTagStyleLock.Unlock()
+	logTagLeft, logTagRight = left, right
+}
+
+// GetLevelTagStyle returns the characters surrounding the level letter in text
+// output.
+func GetLevelTagStyle() (string, string) {
+	logTagStyleLock.RLock()
+	defer logTagStyleLock.RUnlock()
+	return logTagLeft, logTagRight
+}
+
+// SetLevelFormat sets the way the level of log messages is rendered, e.g.
+// LevelSyslogNumber for systems that expect numeric severities; it defaults to
+// LevelLetter. GELF output always uses syslog numbers, as its schema requires.
+func SetLevelFormat(format LevelFormat) {
+	defer changed()
+	logLevelFmtLock.Lock()
+	defer logLevelFmtLock.Unlock()
+	logLevelFormat = format
+}
+
+// GetLevelFormat returns the way the level of log messages is rendered.
+func GetLevelFormat() LevelFormat {
+	logLevelFmtLock.RLock()
+	defer logLevelFmtLock.RUnlock()
+	return logLevelFormat
+}
+
+// SetIndentContinuation sets whether the continuation lines of multi-line
+// messages (e.g. pretty-printed JSON from ToJSON) are indented so that they
+// are aligned under the first line of the message in text output, which keeps
+// them visually grouped with their log line; it does not apply to line
+// templates.
+func SetIndentContinuation(enabled bool) {
+	defer changed()
+	logIndentLock.Lock()
+	defer logIndentLock.Unlock()
+	logIndent = enabled
+}
+
+// GetIndentContinuation returns whether the continuation lines of multi-line
+// messages are indented in text output.
+func GetIndentContinuation() bool {
+	logIndentLock.RLock()
+	defer logIndentLock.RUnlock()
+	return logIndent
+}
+
+// SetLineEnding sets the sequence terminating the lines of log messages, which
+// applies to the new line appended to messages, to continuation lines and to
+// stack traces, in all formats; the default is LineLF. Output is always UTF-8,
+// with no byte order mark, regardless of the line ending.
+func SetLineEnding(ending LineEnding) {
+	defer changed()
+	logLineEndLock.Lock()
+	defer logLineEndLock.Unlock()
+	logLineEnding = ending
+}
+
+// GetLineEnding returns the sequence terminating the lines of log messages.
+func GetLineEnding() LineEnding {
+	logLineEndLock.RLock()
+	defer logLineEndLock.RUnlock()
+	return logLineEnding
+}
+
+// SetPrefix sets a static string, e.g. "[svc-a]", that is written right after
+// the time in text output, so that lines from different services multiplexed
+// into the same stream can be told apart; structured output formats should
+// use fields instead. An empty prefix, which is the default, is not written.
+func SetPrefix(prefix string) {
+	defer changed()
+	logPrefixLock.Lock()
+	defer logPrefixLock.Unlock()
+	logPrefix = prefix
+}
+
+// GetPrefix returns the static string written after the time in text output.
+func GetPrefix() string {
+	logPrefixLock.RLock()
+	defer logPrefixLock.RUnlock()
+	return logPrefix
+}
+
+// SetPrintLevel sets whether the level tag is written at the beginning of the
+// line in text output; the level is still used for filtering and colouring
+// when it is not written, e.g. when the consumer assigns severities on its
+// own. Line templates and structured output formats are not affected.
+func SetPrintLevel(enabled bool) {
+	defer changed()
+	logPrintLvlLock.Lock()
+	defer logPrintLvlLock.Unlock()
+	logPrintLevel = enabled
+}
+
+// GetPrintLevel returns whether the level tag is written in text output.
+func GetPrintLevel() bool {
+	logPrintLvlLock.RLock()
+	defer logPrintLvlLock.RUnlock()
+	return logPrintLevel
+}
+
+// SetMessageKey sets the key of the message in structured (JSON and logfmt)
+// output; it defaults to "msg".
+func SetMessageKey(key string) {
+	defer changed()
+	logKeysLock.Lock()
+	defer logKeysLock.Unlock()
+	logMessageKey = key
+}
+
+// GetMessageKey returns the key of the message in structured output.
+func GetMessageKey() string {
+	logKeysLock.RLock()
+	defer logKeysLock.RUnlock()
+	return logMessageKey
+}
+
+// SetLevelKey sets the key of the log level in structured (JSON and logfmt)
+// output; it defaults to "level".
+func SetLevelKey(key string) {
+	defer changed()
+	logKeysLock.Lock()
+	defer logKeysLock.Unlock()
+	logLevelKey = key
+}
+
+// GetLevelKey returns the key of the log level in structured output.
+func GetLevelKey() string {
+	logKeysLock.RLock()
+	defer logKeysLock.RUnlock()
+	return logLevelKey
+}
+
+// SetTimeKey sets the key of the timestamp in structured (JSON and logfmt)
+// output; it defaults to "time".
+func SetTimeKey(key string) {
+	defer changed()
+	logKeysLock.Lock()
+	defer logKeysLock.Unlock()
+	logTimeKey = key
+}
+
+// GetTimeKey returns the key of the timestamp in structured output.
+func GetTimeKey() string {
+	logKeysLock.RLock()
+	defer logKeysLock.RUnlock()
+	return logTimeKey
+}
+
+// SetCallerKey sets the key of the calling function in structured (JSON and
+// logfmt) output; it defaults to "caller".
+func SetCallerKey(key string) {
+	defer changed()
+	logKeysLock.Lock()
+	defer logKeysLock.Unlock()
+	logCallerKey = key
+}
+
+// GetCallerKey returns the key of the calling function in structured output.
+func GetCallerKey() string {
+	logKeysLock.RLock()
+	defer logKeysLock.RUnlock()
+	return logCallerKey
+}
+
+// SetSourceKey sets the key of the source file and line number in structured
+// (JSON and logfmt) output; it defaults to "source".
+func SetSourceKey(key string) {
+	defer changed()
+	logKeysLock.Lock()
+	defer logKeysLock.Unlock()
+	logSourceKey = key
+}
+
+// GetSourceKey returns the key of the source file and line number in
+// structured output.
+func GetSourceKey() string {
+	logKeysLock.RLock()
+	defer logKeysLock.RUnlock()
+	return logSourceKey
+}
+
+// SetGoroutineKey sets the key of the goroutine ID in structured (JSON and
+// logfmt) output; it defaults to "goroutine".
+func SetGoroutineKey(key string) {
+	defer changed()
+	logKeysLock.Lock()
+	defer logKeysLock.Unlock()
+	logGoroutineKey = key
+}
+
+// GetGoroutineKey returns the key of the goroutine ID in structured output.
+func GetGoroutineKey() string {
+	logKeysLock.RLock()
+	defer logKeysLock.RUnlock()
+	return logGoroutineKey
+}
+
+// SetStackKey sets the key of the stack trace in structured (JSON and logfmt)
+// output.
+func SetStackKey(key string) {
+	defer changed()
+	logKeysLock.Lock()
+	defer logKeysLock.Unlock()
+	logStackKey = key
+}
+
+// GetStackKey returns the key of the stack trace in structured output.
+func GetStackKey() string {
+	logKeysLock.RLock()
+	defer logKeysLock.RUnlock()
+	return logStackKey
+}
+
+// SetSequenceKey sets the key of the sequence number in structured (JSON and
+// logfmt) output.
+func SetSequenceKey(key string) {
+	defer changed()
+	logKeysLock.Lock()
+	defer logKeysLock.Unlock()
+	logSequenceKey = key
+}
+
+// GetSequenceKey returns the key of the sequence number in structured output.
+func GetSequenceKey() string {
+	logKeysLock.RLock()
+	defer logKeysLock.RUnlock()
+	return logSequenceKey
+}
+
+// SetHostKey sets the key of the name of the host in structured (JSON and
+// logfmt) output; it defaults to "host".
+func SetHostKey(key string) {
+	defer changed()
+	logKeysLock.Lock()
+	defer logKeysLock.Unlock()
+	logHostKey = key
+}
+
+// GetHostKey returns the key of the name of the host in structured output.
+func GetHostKey() string {
+	logKeysLock.RLock()
+	defer logKeysLock.RUnlock()
+	return logHostKey
+}
+
+// SetErrorChainKey sets the key of the field holding the chain of a wrapped
+// error; it defaults to "error_chain".
+func SetErrorChainKey(key string) {
+	defer changed()
+	logKeysLock.Lock()
+	defer logKeysLock.Unlock()
+	logErrorChainKey = key
+}
+
+// GetErrorChainKey returns the key of the field holding the chain of a wrapped
+// error.
+func GetErrorChainKey() string {
+	logKeysLock.RLock()
+	defer logKeysLock.RUnlock()
+	return logErrorChainKey
+}
+
+// Record holds all the information about a single log message, as it is passed
+// to the formatters and to the record hooks; the calling function, the source
+// file and the goroutine ID are only filled in when the logger is configured to
+// print them, and are empty (or zero) otherwise.
+type Record struct {
+	// Level is the level of the message.
+	Level LogLevel
+	// Time is the time the message was logged at, or the zero time in test
+	// mode (see SetTestMode).
+	Time time.Time
+	// Function is the name of the calling function, formatted according to the
+	// caller style, or the unknown caller placeholder.
+	Function string
+	// File is the source file of the call site, shortened according to the
+	// source info setting, or the unknown caller placeholder.
+	File string
+	// Line is the line of the call site in the source file, or 0 if unknown.
+	Line int
+	// Goroutine is the ID of the calling goroutine.
+	Goroutine uint64
+	// Host is the name of the host, if it is printed.
+	Host string
+	// Sequence is the sequence number of the message, if sequence numbers are
+	// printed, or 0.
+	Sequence uint64
+	// Message is the message, after redaction.
+	Message string
+	// Fields are the structured fields attached to the message, in order,
+	// starting with the default ones (see SetDefaultFields).
+	Fields []Field
+	// Stack is the stack trace of the call site, one function per line
+	// followed by its file and line on a line indented with a tab, if stack
+	// traces are printed for the level of the message.
+	Stack string
+	// unknownCaller is set when the calling function could not be determined
+	// and Function holds the placeholder.
+	unknownCaller bool
+	// settings is the snapshot of the settings the record was created with,
+	// which is used to render it.
+	settings *settings
+}
+
+// newRecord creates the record for a message at the given level and time (the
+// current time if zero) according to the given settings, collecting the
+// runtime information of the call site skip frames up the stack from the caller
+// of newRecord, if required, unless the call site is given.
+func newRecord(s *settings, level LogLevel, skip int, site *callSite, t time.Time, message string, fields []Field) *Record {
+	if s.testMode {
+		t = time.Time{}
+	} else if t.IsZero() {
+		t = s.clock()
+	}
+	if s.timeTruncate > 0 {
+		t = t.Truncate(s.timeTruncate)
+	}
+	r := &Record{
+		Level:    level,
+		Time:     t,
+		Message:  message,
+		Fields:   withDefaults(s.defaults, fields),
+		settings: s,
+	}
+	required := (level >= s.callerInfoMinLevel || level == auditLevel) && !s.testMode
+	if required && (s.printCallerInfo || s.printSourceInfo != SourceInfoNone) {
+		var function, file string
+		var line int
+		if site != nil {
+			function, file, line = site.function, site.file, site.line
+		} else {
+			function, file, line = callerInfo(s, skip+1)
+		}
+		if s.printCallerInfo {
+			r.Function = function
+			if function == "" {
+				r.Function = s.unknownCaller
+				r.unknownCaller = true
+			}
+		}
+		if s.printSourceInfo != SourceInfoNone {
+			r.File, r.Line = file, line
+			if file == "" {
+				r.File = s.unknownCaller
+			}
+		}
+	}
+	if s.printGoroutineID {
+		r.Goroutine = goroutineID()
+	}
+	if s.printHostname && !s.testMode {
+		r.Host = hostname()
+	}
+	if level >= s.printStackTrace && level < NoneLevel {
+		r.Stack = stackTrace(skip + 1)
+	}
+	if s.redacted {
+		redact(r)
+	}
+	r.Message = truncate(r.Message, s.maxMessageLength)
+	return r
+}
+
+// config returns the snapshot of the settings the record was created with, or
+// of the current ones if it was not created by the logger.
+func (r *Record) config() *settings {
+	if r.settings != nil {
+		return r.settings
+	}
+	return current()
+}
+
+// source returns the file:line representation of the record's call site, or
+// just the placeholder if it is unknown.
+func (r *Record) source() string {
+	if r.Line <= 0 {
+		return r.File
+	}
+	return r.File + ":" + strconv.Itoa(r.Line)
+}
+
+// bufferPool holds the buffers used to assemble lines before writing them to
+// the stream in a single call.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &bytes.Buffer{}
+	},
+}
+
+// maxPooledBufferSize is the capacity above which buffers are not returned to
+// the pool, so that an occasional huge message does not pin memory.
+const maxPooledBufferSize = 64 * 1024
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns a buffer to the pool.
+func putBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() > maxPooledBufferSize {
+		return
+	}
+	buffer.Reset()
+	bufferPool.Put(buffer)
+}
+
+// render appends the record to the buffer as a line formatted according to
+// the current format and rewritten by the line transform, if any, terminated by
+// the current line ending.
+func render(buffer *bytes.Buffer, r *Record) {
+	renderFormat(buffer, r, r.config().format)
+}
+
+// renderColoured appends the record to the buffer as render does, but in the
+// given format and wrapped in the escape codes of the given colour, if not nil,
+// or of the first highlight matching the line; JSON embedded in the message is
+// highlighted in text output, if enabled.
+func renderColoured(buffer *bytes.Buffer, r *Record, format LogFormat, colour *color.Color) {
+	if colour == nil {
+		renderFormat(buffer, r, format)
+		return
+	}
+	line := getBuffer()
+	defer putBuffer(line)
+	renderFormat(line, r, format)
+	if c := highlighted(r.config().highlights, line.Bytes()); c != nil {
+		colour = c
+	}
+	// reset the colour before the line ending, so it does not bleed
+	ending := ""
+	for _, suffix := range []string{"\r\n", "\n"} {
+		if bytes.HasSuffix(line.Bytes(), []byte(suffix)) {
+			ending = suffix
+			line.Truncate(line.Len() - len(suffix))
+			break
+		}
+	}
+	if s := r.config(); s.highlightJSON && (format == FormatText || format == FormatJournal) {
+		if start, end, ok := embeddedJSON(line.Bytes(), r.Message); ok {
+			paint(buffer, colour, line.Bytes()[:start])
+			paintJSON(buffer, colour, line.Bytes()[start:end])
+			paint(buffer, colour, line.Bytes()[end:])
+			buffer.WriteString(ending)
+			return
+		}
+	}
+	paint(buffer, colour, line.Bytes())
+	buffer.WriteString(ending)
+}
+
+// renderFormat appends the record to the buffer as render does, but in the
+// given format.
+func renderFormat(buffer *bytes.Buffer, r *Record, format LogFormat) {
+	start := buffer.Len()
+	switch format {
+	case FormatJSON:
+		renderJSON(buffer, r)
+	case FormatLogfmt:
+		renderLogfmt(buffer, r)
+	case FormatGELF:
+		renderGELF(buffer, r)
+	case FormatJournal:
+		renderJournal(buffer, r)
+	default:
+		renderText(buffer, r)
+	}
+	s := r.config()
+	transformLine(buffer, start, s.lineTransform)
+	if s.lineEnding == LineCRLF {
+		crlf(buffer, start)
+	}
+}
+
+// crlf replaces the line feeds that are not already preceded by a carriage
+// return with CRLF sequences in the buffer, from the given offset.
+func crlf(buffer *bytes.Buffer, start int) {
+	if bytes.IndexByte(buffer.Bytes()[start:], '\n') < 0 {
+		return
+	}
+	lines := append([]byte(nil), buffer.Bytes()[start:]...)
+	buffer.Truncate(start)
+	for i, b := range lines {
+		if b == '\n' && (i == 0 || lines[i-1] != '\r') {
+			buffer.WriteByte('\r')
+		}
+		buffer.WriteByte(b)
+	}
+}
+
+// renderText appends the record to the buffer as a human readable line, laid
+// out according to the line template if one is set; with the default layout, a
+// newline is appended unless the message already ends with one (or with a
+// carriage return, which allows overwriting the line on terminals).
+func renderText(buffer *bytes.Buffer, r *Record) {
+	s := r.config()
+	if template := s.template; template != nil {
+		renderTemplate(buffer, r, template)
+		return
+	}
+	start := buffer.Len()
+	// the segments of the header are separated by a space, and the header by
+	// a dash from the rest of the line, only if they are present
+	if r.Sequence != 0 {
+		buffer.WriteByte('#')
+		buffer.WriteString(strconv.FormatUint(r.Sequence, 10))
+	}
+	if s.printLevel {
+		separate(buffer, start)
+		buffer.WriteString(s.tag(r.Level))
+	}
+	if !r.Time.IsZero() {
+		if stamp := r.Time.Format(s.timeFormat); stamp != "" {
+			separate(buffer, start)
+			buffer.WriteString(stamp)
+		}
+	}
+	if r.Host != "" {
+		separate(buffer, start)
+		buffer.WriteString(r.Host)
+	}
+	if prefix := s.prefix; prefix != "" {
+		separate(buffer, start)
+		buffer.WriteString(prefix)
+	}
+	if r.Goroutine != 0 {
+		separate(buffer, start)
+		buffer.WriteString("g:")
+		buffer.WriteString(strconv.FormatUint(r.Goroutine, 10))
+	}
+	if buffer.Len() > start {
+		buffer.WriteString(" - ")
+	}
+	if r.Function != "" {
+		buffer.WriteString(r.Function)
+		buffer.WriteString(": ")
+	}
+	message := r.Message
+	if r.File != "" || len(r.Fields) > 0 {
+		message = strings.TrimSuffix(message, "\n")
+	}
+	if s.indent {
+		indent := strings.Repeat(" ", utf8.RuneCount(buffer.Bytes()[start:]))
+		body := strings.TrimSuffix(message, "\n")
+		message = strings.ReplaceAll(body, "\n", "\n"+indent) + message[len(body):]
+	}
+	buffer.WriteString(message)
+	for _, field := range r.Fields {
+		buffer.WriteByte(' ')
+		buffer.WriteString(field.String())
+	}
+	if r.File != "" {
+		buffer.WriteString(" (")
+		buffer.WriteString(r.source())
+		buffer.WriteByte(')')
+	}
+	if line := buffer.Bytes()[start:]; !bytes.HasSuffix(line, []byte("\n")) && !bytes.HasSuffix(line, []byte("\r")) {
+		buffer.WriteByte('\n')
+	}
+	if r.Stack != "" {
+		for _, line := range strings.SplitAfter(r.Stack, "\n") {
+			if line != "" {
+				buffer.WriteByte('\t')
+				buffer.WriteString(line)
+			}
+		}
+	}
+}
+
+// separate writes a space if anything was written to the buffer after start.
+func separate(buffer *bytes.Buffer, start int) {
+	if buffer.Len() > start {
+		buffer.WriteByte(' ')
+	}
+}
+
+// renderJSON appends the record to the buffer as a single line JSON object.
+func renderJSON(buffer *bytes.Buffer, r *Record) {
+	s := r.config()
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	buffer.WriteByte('{')
+	if r.Sequence != 0 {
+		appendJSON(buffer, encoder, s.sequenceKey, r.Sequence)
+		buffer.WriteByte(',')
+	}
+	if s.levelFormat == LevelSyslogNumber {
+		appendJSON(buffer, encoder, s.levelKey, r.Level.severity())
+	} else {
+		appendJSON(buffer, encoder, s.levelKey, r.Level.name())
+	}
+	if !r.Time.IsZero() {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, s.timeKey, r.Time.Format(s.timeFormat))
+	}
+	if r.unknownCaller {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, s.callerKey, nil)
+	} else if r.Function != "" {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, s.callerKey, r.Function)
+	}
+	if r.File != "" && r.Line <= 0 {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, s.sourceKey, nil)
+	} else if r.File != "" {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, s.sourceKey, r.source())
+	}
+	if r.Goroutine != 0 {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, s.goroutineKey, r.Goroutine)
+	}
+	if r.Host != "" {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, s.hostKey, r.Host)
+	}
+	buffer.WriteByte(',')
+	appendJSON(buffer, encoder, s.messageKey, strings.TrimRight(r.Message, "\r\n"))
+	for _, field := range r.Fields {
+		buffer.WriteByte(',')
+		appendJSONField(buffer, encoder, field)
+	}
+	if r.Stack != "" {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, s.stackKey, r.Stack)
+	}
+	buffer.WriteString("}\n")
+}
+
+// appendJSONField appends a field to the buffer through the encoder, which must
+// write to the same buffer; groups are rendered as nested objects.
+func appendJSONField(buffer *bytes.Buffer, encoder *json.Encoder, field Field) {
+	group, ok := field.Value.([]Field)
+	if !ok {
+		appendJSON(buffer, encoder, field.Key, jsonValue(field.Value))
+		return
+	}
+	appendJSONString(buffer, encoder, field.Key)
+	buffer.WriteString(":{")
+	for i, field := range group {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		appendJSONField(buffer, encoder, field)
+	}
+	buffer.WriteByte('}')
+}
+
+// appendJSON appends a "key":value pair to the buffer; strings and integers,
+// which make up most of a record, are appended directly, while other values go
+// through the encoder, which must write to the same buffer.
+func appendJSON(buffer *bytes.Buffer, encoder *json.Encoder, key string, value interface{}) {
+	appendJSONString(buffer, encoder, key)
+	buffer.WriteByte(':')
+	switch value := value.(type) {
+	case nil:
+		buffer.WriteString("null")
+	case string:
+		appendJSONString(buffer, encoder, value)
+	case bool:
+		buffer.Write(strconv.AppendBool(buffer.AvailableBuffer(), value))
+	case int:
+		buffer.Write(strconv.AppendInt(buffer.AvailableBuffer(), int64(value), 10))
+	case int64:
+		buffer.Write(strconv.AppendInt(buffer.AvailableBuffer(), value, 10))
+	case uint64:
+		buffer.Write(strconv.AppendUint(buffer.AvailableBuffer(), value, 10))
+	default:
+		if err := encoder.Encode(value); err != nil {
+			encoder.Encode(err.Error())
+		}
+		buffer.Truncate(buffer.Len() - 1)
+	}
+}
+
+// appendJSONString appends a string to the buffer as a JSON string; strings of
+// printable ASCII characters that need no escaping are quoted directly, while
+// the others go through the encoder, which must write to the same buffer.
+func appendJSONString(buffer *bytes.Buffer, encoder *json.Encoder, s string) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c > 0x7e || c == '"' || c == '\\' {
+			encoder.Encode(s)
+			buffer.Truncate(buffer.Len() - 1)
+			return
+		}
+	}
+	buffer.WriteByte('"')
+	buffer.WriteString(s)
+	buffer.WriteByte('"')
+}
+
+// jsonValue returns the value to be encoded in JSON output for a field value:
+// durations are rendered as in text output, the rest keeps its native type.
+func jsonValue(value interface{}) interface{} {
+	if _, ok := value.(time.Duration); ok {
+		return formatValue(value)
+	}
+	return value
+}
+
+// renderJournal appends the record to the buffer as renderText does, prefixing
+// each line with the syslog priority of the level, as sd-daemon(3) describes.
+func renderJournal(buffer *bytes.Buffer, r *Record) {
+	prefix := "<" + strconv.Itoa(r.Level.severity()) + ">"
+	text := getBuffer()
+	defer putBuffer(text)
+	renderText(text, r)
+	for _, line := range bytes.SplitAfter(text.Bytes(), []byte("\n")) {
+		if len(line) > 0 {
+			buffer.WriteString(prefix)
+			buffer.Write(line)
+		}
+	}
+}
+
+// renderLogfmt appends the record to the buffer as a line of key=value pairs.
+func renderLogfmt(buffer *bytes.Buffer, r *Record) {
+	s := r.config()
+	if r.Sequence != 0 {
+		appendLogfmt(buffer, s.sequenceKey, strconv.FormatUint(r.Sequence, 10))
+		buffer.WriteByte(' ')
+	}
+	if s.levelFormat == LevelSyslogNumber {
+		appendLogfmt(buffer, s.levelKey, strconv.Itoa(r.Level.severity()))
+	} else {
+		appendLogfmt(buffer, s.levelKey, r.Level.name())
+	}
+	if !r.Time.IsZero() {
+		buffer.WriteByte(' ')
+		appendLogfmt(buffer, s.timeKey, r.Time.Format(s.timeFormat))
+	}
+	if r.Function != "" {
+		buffer.WriteByte(' ')
+		appendLogfmt(buffer, s.callerKey, r.Function)
+	}
+	if r.File != "" {
+		buffer.WriteByte(' ')
+		appendLogfmt(buffer, s.sourceKey, r.source())
+	}
+	if r.Goroutine != 0 {
+		buffer.WriteByte(' ')
+		appendLogfmt(buffer, s.goroutineKey, strconv.FormatUint(r.Goroutine, 10))
+	}
+	if r.Host != "" {
+		buffer.WriteByte(' ')
+		appendLogfmt(buffer, s.hostKey, r.Host)
+	}
+	buffer.WriteByte(' ')
+	appendLogfmt(buffer, s.messageKey, strings.TrimRight(r.Message, "\r\n"))
+	for _, field := range flatten(r.Fields) {
+		buffer.WriteByte(' ')
+		appendLogfmt(buffer, field.Key, formatValue(field.Value))
+	}
+	if r.Stack != "" {
+		buffer.WriteByte(' ')
+		appendLogfmt(buffer, s.stackKey, r.Stack)
+	}
+	buffer.WriteByte('\n')
+}
+
+// appendLogfmt appends a key=value pair to the buffer, quoting the value if
+// it is empty or contains spaces, quotes, equal signs or control characters.
+func appendLogfmt(buffer *bytes.Buffer, key string, value string) {
+	buffer.WriteString(key)
+	buffer.WriteByte('=')
+	if value == "" || strings.IndexFunc(value, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || unicode.IsSpace(r) || unicode.IsControl(r)
+	}) >= 0 {
+		buffer.WriteString(strconv.Quote(value))
+	} else {
+		buffer.WriteString(value)
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatJSON(t *testing.T) {
+	defer SetFormat(GetFormat())
+	defer SetMessageKey(GetMessageKey())
+	defer SetLevelKey(GetLevelKey())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetFormat(FormatJSON)
+	SetPrintSourceInfo(SourceInfoShort)
+	SetMessageKey("message")
+	SetLevelKey("severity")
+	Warnf("disk <%d%%> full\n", 90)
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	if entry["message"] != "disk <90%> full" {
+		t.Errorf("unexpected message in %q", buffer.String())
+	}
+	if entry["severity"] != "warning" {
+		t.Errorf("unexpected level in %q", buffer.String())
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Errorf("missing time in %q", buffer.String())
+	}
+	if source, ok := entry["source"].(string); !ok || !regexp.MustCompile(`^format_test\.go:\d+$`).MatchString(source) {
+		t.Errorf("unexpected source in %q", buffer.String())
+	}
+}
+
+func TestFormatLogfmt(t *testing.T) {
+	defer SetFormat(GetFormat())
+	defer SetTimeFormat(GetTimeFormat())
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetFormat(FormatLogfmt)
+	SetTimeFormat("15:04:05")
+	SetPrintCallerInfo(false)
+	SetPrintSourceInfo(SourceInfoNone)
+	Errorln("connection", "refused")
+
+	re := regexp.MustCompile(`^level=error time=\d\d:\d\d:\d\d msg="connection refused"\n$`)
+	if !re.MatchString(buffer.String()) {
+		t.Errorf("unexpected logfmt line %q", buffer.String())
+	}
+}
+
+func TestLevelTagStyle(t *testing.T) {
+	defer SetLevelTagStyle(GetLevelTagStyle())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	for _, test := range []struct {
+		left, right string
+		prefix      string
+	}{
+		{"<", ">", "<W> "},
+		{"", "|", "W| "},
+		{"", "", "W "},
+	} {
+		buffer.Reset()
+		SetLevelTagStyle(test.left, test.right)
+		Warnf("styled")
+		if !strings.HasPrefix(buffer.String(), test.prefix) {
+			t.Errorf("expected prefix %q, got %q", test.prefix, buffer.String())
+		}
+	}
+
+	// detection in Println is decoupled from the style
+	buffer.Reset()
+	Println("[E]", "detected")
+	if !strings.HasPrefix(buffer.String(), "E ") || !strings.Contains(buffer.String(), "detected") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+// indented is a json.Marshaler producing multi-line output.
+type indented struct{}
+
+func (indented) MarshalJSON() ([]byte, error) {
+	return []byte("{\n  \"nested\": [\n    1,\n    2\n  ]\n}"), nil
+}
+
+func TestFormatJSONEncoding(t *testing.T) {
+	r := &Record{
+		Level:   WarnLevel,
+		Message: "say \"hi\"\tto ünïcode\u2028",
+		Fields:  []Field{Str("plain", "value"), Int("count", -3), Bool("ok", true), Float("ratio", 0.5), {Key: "none"}},
+	}
+	r.settings = current()
+	buffer := &bytes.Buffer{}
+	renderJSON(buffer, r)
+	expected := `{"level":"warning","msg":"say \"hi\"\tto ünïcode\u2028","plain":"value","count":-3,"ok":true,"ratio":0.5,"none":null}` + "\n"
+	if buffer.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buffer.String())
+	}
+}
+
+func TestFormatJSONSingleLine(t *testing.T) {
+	defer SetFormat(GetFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetFormat(FormatJSON)
+	Errorf("first line\nsecond line\r\nthird line\n", Fields{
+		"multi\nline key": "multi\nline value",
+		"object":          indented{},
+		"invalid":         make(chan int),
+	})
+	Infoln(ToJSON(map[string]int{"a": 1, "b": 2}))
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records on 2 lines, got %d lines: %q", len(lines), buffer.String())
+	}
+	for _, line := range lines {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Errorf("invalid JSON line %q: %v", line, err)
+		}
+	}
+}
+
+func TestLevelFormat(t *testing.T) {
+	defer SetLevelFormat(GetLevelFormat())
+	defer SetFormat(GetFormat())
+	defer SetTestMode(GetTestMode())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetTestMode(true)
+	for _, test := range []struct {
+		format   LogFormat
+		level    LevelFormat
+		expected string
+	}{
+		{FormatText, LevelLetter, "[E] - failed\n"},
+		{FormatText, LevelWord, "error   - failed\n"},
+		{FormatText, LevelSyslogNumber, "3 - failed\n"},
+		{FormatJSON, LevelWord, `{"level":"error","msg":"failed"}` + "\n"},
+		{FormatJSON, LevelSyslogNumber, `{"level":3,"msg":"failed"}` + "\n"},
+		{FormatLogfmt, LevelSyslogNumber, "level=3 msg=failed\n"},
+	} {
+		buffer.Reset()
+		SetFormat(test.format)
+		SetLevelFormat(test.level)
+		Errorln("failed")
+		if buffer.String() != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, buffer.String())
+		}
+	}
+}
+
+func TestLevelWordPadding(t *testing.T) {
+	defer SetLevelFormat(GetLevelFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevelFormat(LevelWord)
+	Infoln("first")
+	Warnln("second")
+	lines := strings.Split(buffer.String(), "\n")
+	if strings.Index(lines[0], " - ") != strings.Index(lines[1], " - ") {
+		t.Errorf("columns do not line up in %q", buffer.String())
+	}
+}
+
+func TestFormatJournal(t *testing.T) {
+	defer SetFormat(GetFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetFormat(FormatJournal)
+	Infoln("started")
+	Errorln("failed\nwith details")
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %q", buffer.String())
+	}
+	for i, prefix := range []string{"<6>[I] ", "<3>[E] ", "<3>with details"} {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("expected line %d to start with %q, got %q", i, prefix, lines[i])
+		}
+	}
+}
+
+func TestFormatGELF(t *testing.T) {
+	defer SetFormat(GetFormat())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetFormat(FormatGELF)
+	SetPrintSourceInfo(SourceInfoShort)
+	Errorf("request failed\nstack trace", Str("id", "abc"), Str("user name", "joe"), Int("status", 500))
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	for key, expected := range map[string]interface{}{
+		"version":       "1.1",
+		"short_message": "request failed",
+		"full_message":  "request failed\nstack trace",
+		"level":         float64(3),
+		"_file":         "format_test.go",
+		"__id":          "abc",
+		"_user_name":    "joe",
+		"_status":       float64(500),
+	} {
+		if entry[key] != expected {
+			t.Errorf("expected %q to be %v, got %v", key, expected, entry[key])
+		}
+	}
+	for _, key := range []string{"host", "timestamp", "_line"} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("missing %q in %q", key, buffer.String())
+		}
+	}
+}
+
+func TestIndentContinuation(t *testing.T) {
+	defer SetIndentContinuation(GetIndentContinuation())
+	defer SetTimeFormat(GetTimeFormat())
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetTimeFormat("15:04")
+	SetPrintCallerInfo(false)
+	SetPrintSourceInfo(SourceInfoNone)
+	SetIndentContinuation(true)
+	Infoln(ToJSON(map[string]int{"a": 1}))
+
+	expected := "[I] 00:00 - {\n" +
+		"              \"a\": 1\n" +
+		"            }\n"
+	actual := regexp.MustCompile(`\d\d:\d\d`).ReplaceAllString(buffer.String(), "00:00")
+	if actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestLineEnding(t *testing.T) {
+	defer SetLineEnding(GetLineEnding())
+	defer SetFormat(GetFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLineEnding(LineCRLF)
+	for _, format := range []LogFormat{FormatText, FormatJSON, FormatLogfmt} {
+		buffer.Reset()
+		SetFormat(format)
+		Infof("first")
+		Infoln("second")
+		if strings.Count(buffer.String(), "\r\n") != 2 || strings.Count(buffer.String(), "\n") != 2 {
+			t.Errorf("format %d: expected two CRLF lines, got %q", format, buffer.String())
+		}
+	}
+
+	buffer.Reset()
+	SetFormat(FormatText)
+	Infof("multi\nline")
+	Println("raw", "line")
+	if !regexp.MustCompile(`multi\r\nline \(format_test\.go:\d+\)\r\nraw line\r\n$`).MatchString(buffer.String()) {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	defer SetPrefix(GetPrefix())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrefix("[svc-a]")
+	Infof("started")
+	if !regexp.MustCompile(`^\[I\] \S+ \[svc-a\] - .*: started`).MatchString(buffer.String()) {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+func TestPrintLevel(t *testing.T) {
+	defer SetPrintLevel(GetPrintLevel())
+	defer SetLevel(GetLevel())
+	defer SetTimeFormat(GetTimeFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrintLevel(false)
+	SetLevel(InfoLevel)
+	SetTimeFormat("15:04:05")
+	Debugf("filtered out")
+	Warnf("untagged")
+	if !regexp.MustCompile(`^\d\d:\d\d:\d\d - .*: untagged`).MatchString(buffer.String()) {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+
+	// no header at all, hence no separators
+	defer SetTestMode(GetTestMode())
+	buffer.Reset()
+	SetTestMode(true)
+	Warnf("bare")
+	if buffer.String() != "bare\n" {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+
+	// the tag alone, without a time
+	buffer.Reset()
+	SetTestMode(false)
+	SetPrintLevel(true)
+	SetTimeFormat("")
+	Warnf("tagged")
+	if !regexp.MustCompile(`^\[W\] - .*: tagged`).MatchString(buffer.String()) {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+func TestUnknownCaller(t *testing.T) {
+	defer SetUnknownCallerPlaceholder(GetUnknownCallerPlaceholder())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	defer SetFormat(GetFormat())
+
+	SetPrintSourceInfo(SourceInfoShort)
+	SetUnknownCallerPlaceholder("-")
+	// skip more frames than there are on the stack
+	r := newRecord(current(), InfoLevel, 1000, nil, time.Time{}, "lost", nil)
+	buffer := &bytes.Buffer{}
+	renderText(buffer, r)
+	if !strings.HasSuffix(buffer.String(), " - -: lost (-)\n") {
+		t.Errorf("unexpected text line %q", buffer.String())
+	}
+
+	buffer.Reset()
+	renderJSON(buffer, r)
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	for _, key := range []string{"caller", "source"} {
+		if value, ok := entry[key]; !ok || value != nil {
+			t.Errorf("expected null %s in %q", key, buffer.String())
+		}
+	}
+}
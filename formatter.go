@@ -0,0 +1,167 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// Formatter renders records for the main stream in place of the built-in
+// formats (see SetFormatter); it is called concurrently, so it must be safe for
+// concurrent use.
+type Formatter interface {
+	// Format appends the rendering of the record to the buffer, which is
+	// then written to the stream, if not empty.
+	Format(buffer *bytes.Buffer, r *Record)
+}
+
+// BatchFormatter is a Formatter that can hold records across calls, e.g. to
+// write them in batches, appending nothing to the buffer until a batch is
+// complete; the records it holds are written when Flush is called, before a
+// fatal or panic message is logged, and before the stream or the formatter is
+// replaced.
+type BatchFormatter interface {
+	Formatter
+	// Flush appends the records held so far, if any, to the buffer and
+	// forgets them.
+	Flush(buffer *bytes.Buffer)
+}
+
+var (
+	logFormatter     Formatter
+	logFormatterLock sync.RWMutex
+)
+
+// SetFormatter sets a custom formatter for the main stream, which overrides the
+// format set with SetFormat, the colours and the line transform and ending; the
+// records held by the previous formatter, if any, are written first. Passing
+// nil, which is the default, restores the built-in formats. Streams added with
+// AddStream, the crash file, the fallback stream and Format keep using the
+// built-in formats.
+func SetFormatter(formatter Formatter) {
+	logFormatterLock.Lock()
+	previous := logFormatter
+	logFormatter = formatter
+	logFormatterLock.Unlock()
+	changed()
+	// flush the previous formatter after it is replaced, so that no record can
+	// be added to it once it has been flushed
+	flushRecords(previous)
+}
+
+// GetFormatter returns the custom formatter for the main stream, or nil if the
+// built-in formats are used.
+func GetFormatter() Formatter {
+	logFormatterLock.RLock()
+	defer logFormatterLock.RUnlock()
+	return logFormatter
+}
+
+// flushFormatter writes the records held by the current formatter, if it is a
+// BatchFormatter, to the stream, after any lines batched by the logger (see
+// SetWriteBatch); failures are reported to the write error handler.
+func flushFormatter() error {
+	return flushRecords(GetFormatter())
+}
+
+// flushRecords writes the records held by the given formatter, if it is a
+// BatchFormatter, as flushFormatter does.
+func flushRecords(f Formatter) error {
+	formatter, ok := f.(BatchFormatter)
+	if !ok {
+		return nil
+	}
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	formatter.Flush(buffer)
+	if buffer.Len() == 0 {
+		return nil
+	}
+	if err := flushBatch(); err != nil {
+		return err
+	}
+	if _, err := GetStream().Write(buffer.Bytes()); err != nil {
+		if handler := GetWriteErrorHandler(); handler != nil {
+			handler(err)
+		}
+		return err
+	}
+	return nil
+}
+
+// NewJSONBatchFormatter returns a BatchFormatter that writes records as JSON
+// arrays of up to maxRecords objects, with one object per line, e.g.
+//
+//	[{"level":"info","time":"...","msg":"first"},
+//	{"level":"info","time":"...","msg":"second"}]
+//
+// for ingestion systems that load logs in batches; the objects are rendered as
+// in FormatJSON. A batch is written when it is full or, if maxDelay is greater
+// than 0, when maxDelay has elapsed since its first record was logged,
+// whichever comes first. Since records are held, the logging functions return
+// 0 bytes written until the batch is complete.
+func NewJSONBatchFormatter(maxRecords int, maxDelay time.Duration) BatchFormatter {
+	return &jsonBatchFormatter{maxRecords: maxRecords, maxDelay: maxDelay}
+}
+
+// jsonBatchFormatter is the BatchFormatter returned by NewJSONBatchFormatter.
+type jsonBatchFormatter struct {
+	lock       sync.Mutex
+	maxRecords int
+	maxDelay   time.Duration
+	pending    bytes.Buffer
+	records    int
+	timer      *time.Timer
+}
+
+// Format adds the record to the current batch, and appends the batch to the
+// buffer if it is full.
+func (f *jsonBatchFormatter) Format(buffer *bytes.Buffer, r *Record) {
+	line := getBuffer()
+	defer putBuffer(line)
+	renderJSON(line, r)
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.records == 0 {
+		f.pending.WriteByte('[')
+	} else {
+		f.pending.WriteString(",\n")
+	}
+	f.pending.Write(bytes.TrimSuffix(line.Bytes(), []byte("\n")))
+	f.records++
+	if f.records >= f.maxRecords {
+		f.flush(buffer)
+	} else if f.timer == nil && f.maxDelay > 0 {
+		f.timer = time.AfterFunc(f.maxDelay, func() {
+			// the formatter may no longer be the current one
+			flushRecords(f)
+		})
+	}
+}
+
+// Flush appends the current batch, if any, to the buffer.
+func (f *jsonBatchFormatter) Flush(buffer *bytes.Buffer) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.flush(buffer)
+}
+
+// flush appends the current batch, if any, to the buffer and starts a new one;
+// it must be called with the lock held.
+func (f *jsonBatchFormatter) flush(buffer *bytes.Buffer) {
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	if f.records == 0 {
+		return
+	}
+	buffer.Write(f.pending.Bytes())
+	buffer.WriteString("]\n")
+	f.pending.Reset()
+	f.records = 0
+}
@@ -0,0 +1,105 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upperFormatter renders the message in upper case.
+type upperFormatter struct{}
+
+func (upperFormatter) Format(buffer *bytes.Buffer, r *Record) {
+	buffer.WriteString(strings.ToUpper(r.Message) + "\n")
+}
+
+func TestSetFormatter(t *testing.T) {
+	defer SetFormatter(GetFormatter())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetFormatter(upperFormatter{})
+	if n, err := Infoln("hello"); err != nil || n != 6 || buffer.String() != "HELLO\n" {
+		t.Errorf("unexpected output %q (%d, %v)", buffer.String(), n, err)
+	}
+	buffer.Reset()
+	SetFormatter(nil)
+	Infoln("hello")
+	if !strings.HasPrefix(buffer.String(), "[I] ") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+func TestJSONBatchFormatter(t *testing.T) {
+	defer SetFormatter(GetFormatter())
+	writer := &countingWriter{}
+	defer WithWriter(writer, false)()
+
+	SetFormatter(NewJSONBatchFormatter(3, time.Hour))
+	for _, message := range []string{"one", "two", "three", "four"} {
+		Infoln(message)
+	}
+	lines, writes := writer.state()
+	if writes != 1 || strings.Count(lines, "\n") != 3 {
+		t.Errorf("expected a batch of 3 lines in 1 write, got %d writes: %q", writes, lines)
+	}
+	batch := []map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines), &batch); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines, err)
+	}
+	if len(batch) != 3 || batch[2]["msg"] != "three" {
+		t.Errorf("unexpected batch %v", batch)
+	}
+
+	if err := Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines, writes = writer.state()
+	if writes != 2 || !strings.HasSuffix(lines, `"msg":"four"}]`+"\n") {
+		t.Errorf("expected the partial batch to be flushed, got %d writes: %q", writes, lines)
+	}
+
+	SetFormatter(NewJSONBatchFormatter(100, 10*time.Millisecond))
+	Infoln("five")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if lines, writes = writer.state(); writes == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the batch to be written after the delay, got %d writes: %q", writes, lines)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !strings.HasSuffix(lines, `"msg":"five"}]`+"\n") {
+		t.Errorf("unexpected batch written after the delay %q", lines)
+	}
+
+	// a batch held by a formatter that is no longer the current one is still
+	// written when its delay elapses
+	SetFormatter(NewJSONBatchFormatter(100, 10*time.Millisecond))
+	Infoln("six")
+	logFormatterLock.Lock()
+	logFormatter = nil
+	logFormatterLock.Unlock()
+	changed()
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		if lines, writes = writer.state(); writes == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the batch of the replaced formatter to be written, got %d writes: %q", writes, lines)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !strings.HasSuffix(lines, `"msg":"six"}]`+"\n") {
+		t.Errorf("unexpected batch written after the delay %q", lines)
+	}
+}
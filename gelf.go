@@ -0,0 +1,107 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// severity returns the syslog severity (RFC 5424) corresponding to the log
+// level, from 0 (emergency) to 7 (debug).
+func (l LogLevel) severity() int {
+	switch l {
+	case TraceLevel, DebugLevel:
+		return 7
+	case InfoLevel:
+		return 6
+	case auditLevel:
+		return 5
+	case WarnLevel:
+		return 4
+	case ErrorLevel:
+		return 3
+	case FatalLevel:
+		return 2
+	case PanicLevel:
+		return 1
+	}
+	return 7
+}
+
+// renderGELF appends the record to the buffer as a GELF 1.1 JSON object, on a
+// single line: the first line of the message is the short message and the
+// whole message, followed by the stack trace if any, is the full message if it
+// is longer; the runtime information and the fields are additional fields,
+// whose names are prefixed with an underscore (groups are flattened, since
+// GELF does not nest), and unknown runtime information is omitted. GELF does
+// not use the configurable keys and the time format, since its schema is
+// fixed.
+func renderGELF(buffer *bytes.Buffer, r *Record) {
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	message := strings.TrimRight(r.Message, "\r\n")
+	short := message
+	if i := strings.IndexAny(message, "\r\n"); i >= 0 {
+		short = message[:i]
+	}
+	buffer.WriteString(`{"version":"1.1",`)
+	appendJSON(buffer, encoder, "host", hostname())
+	buffer.WriteByte(',')
+	appendJSON(buffer, encoder, "short_message", short)
+	if r.Stack != "" {
+		message += "\n" + r.Stack
+	}
+	if short != message {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, "full_message", message)
+	}
+	if !r.Time.IsZero() {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, "timestamp", float64(r.Time.UnixMilli())/1000)
+	}
+	buffer.WriteByte(',')
+	appendJSON(buffer, encoder, "level", r.Level.severity())
+	if r.Function != "" && !r.unknownCaller {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, "_caller", r.Function)
+	}
+	if r.File != "" && r.Line > 0 {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, "_file", r.File)
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, "_line", r.Line)
+	}
+	if r.Goroutine != 0 {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, "_goroutine", r.Goroutine)
+	}
+	if r.Sequence != 0 {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, "_seq", r.Sequence)
+	}
+	for _, field := range flatten(r.Fields) {
+		buffer.WriteByte(',')
+		appendJSON(buffer, encoder, gelfKey(field.Key), jsonValue(field.Value))
+	}
+	buffer.WriteString("}\n")
+}
+
+// gelfKey returns the name of the GELF additional field for a field key: it is
+// prefixed with an underscore and any character GELF does not allow is
+// replaced with an underscore; "_id", which is reserved, becomes "__id".
+func gelfKey(key string) string {
+	key = "_" + strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, key)
+	if key == "_id" {
+		key = "__id"
+	}
+	return key
+}
@@ -0,0 +1,53 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+var (
+	logPrintGoroutineID     bool
+	logPrintGoroutineIDLock sync.RWMutex
+)
+
+// SetPrintGoroutineID enables or disables the automatic addition of the ID of
+// the calling goroutine to the log messages (e.g. "[D] <time> g:42 - message").
+// NOTE: Go does not expose goroutine IDs, so they are parsed out of a stack
+// dump: enabling this feature has a significant impact on performances and
+// should be reserved to debugging concurrency issues.
+func SetPrintGoroutineID(enabled bool) {
+	defer changed()
+	logPrintGoroutineIDLock.Lock()
+	defer logPrintGoroutineIDLock.Unlock()
+	logPrintGoroutineID = enabled
+}
+
+// GetPrintGoroutineID returns whether the automatic addition of the ID of the
+// calling goroutine to the log messages is enabled.
+func GetPrintGoroutineID() bool {
+	logPrintGoroutineIDLock.RLock()
+	defer logPrintGoroutineIDLock.RUnlock()
+	return logPrintGoroutineID
+}
+
+// goroutineID returns the ID of the calling goroutine, by parsing the header
+// of its stack dump ("goroutine 42 [running]:"); it returns 0 on failure.
+func goroutineID() uint64 {
+	var stack [64]byte
+	header := stack[:runtime.Stack(stack[:], false)]
+	header = bytes.TrimPrefix(header, []byte("goroutine "))
+	if i := bytes.IndexByte(header, ' '); i > 0 {
+		header = header[:i]
+	}
+	id, err := strconv.ParseUint(string(header), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
@@ -0,0 +1,31 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestPrintGoroutineID(t *testing.T) {
+	defer SetPrintGoroutineID(GetPrintGoroutineID())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrintGoroutineID(true)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Infof("from another goroutine")
+	}()
+	<-done
+	Infof("from the test goroutine")
+
+	ids := regexp.MustCompile(` g:(\d+) - `).FindAllStringSubmatch(buffer.String(), -1)
+	if len(ids) != 2 || ids[0][1] == ids[1][1] || ids[0][1] == "0" {
+		t.Errorf("unexpected goroutine IDs in %q", buffer.String())
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// highlight is a pattern registered through AddHighlight, with its colour.
+type highlight struct {
+	pattern *regexp.Regexp
+	colour  *color.Color
+}
+
+var (
+	logHighlights     []highlight
+	logHighlightsLock sync.RWMutex
+)
+
+// AddHighlight colours the lines that match the given regular expression with
+// the given attributes, instead of the colour of their level, e.g.
+//
+//	log.AddHighlight("timeout", color.FgRed, color.Bold)
+//
+// draws the eye to any line mentioning a timeout, whatever its level, while
+// scanning a live log; the pattern is matched against the whole formatted line,
+// including the fields, and the first matching pattern, in the order they were
+// added, wins. Highlights only apply to colourised streams. It returns an error
+// if the pattern is not a valid regular expression.
+func AddHighlight(pattern string, attributes ...color.Attribute) error {
+	defer changed()
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid highlight pattern %q: %w", pattern, err)
+	}
+	logHighlightsLock.Lock()
+	defer logHighlightsLock.Unlock()
+	// copy on write, so that the settings can share the slice
+	highlights := make([]highlight, len(logHighlights), len(logHighlights)+1)
+	copy(highlights, logHighlights)
+	logHighlights = append(highlights, highlight{pattern: re, colour: newColor(attributes...)})
+	return nil
+}
+
+// ClearHighlights removes all the patterns registered through AddHighlight.
+func ClearHighlights() {
+	defer changed()
+	logHighlightsLock.Lock()
+	defer logHighlightsLock.Unlock()
+	logHighlights = nil
+}
+
+// highlighted returns the colour of the first highlight matching the line, or
+// nil if none does.
+func highlighted(highlights []highlight, line []byte) *color.Color {
+	for _, h := range highlights {
+		if h.pattern.Match(line) {
+			return h.colour
+		}
+	}
+	return nil
+}
+
+var (
+	logHighlightJSON     bool
+	logHighlightJSONLock sync.RWMutex
+)
+
+// jsonColours are the colours of the tokens of JSON embedded in messages.
+var jsonColours = struct {
+	key, str, number, literal *color.Color
+}{
+	key:     newColor(color.FgCyan),
+	str:     newColor(color.FgGreen),
+	number:  newColor(color.FgYellow),
+	literal: newColor(color.FgMagenta),
+}
+
+// SetHighlightJSON enables or disables the syntax highlighting of JSON embedded
+// in messages in text output on colourised streams, e.g. the output of ToJSON
+// or TraceDump: if the message, from its first brace or bracket to its end, is
+// valid JSON, keys, strings, numbers and literals get colours of their own,
+// while the rest of the line keeps the colour of its level. It is disabled by
+// default, since it costs the validation of every message on colourised
+// streams; it has no effect on lines with indented continuation lines (see
+// SetIndentContinuation).
+func SetHighlightJSON(enabled bool) {
+	defer changed()
+	logHighlightJSONLock.Lock()
+	defer logHighlightJSONLock.Unlock()
+	logHighlightJSON = enabled
+}
+
+// GetHighlightJSON returns whether JSON embedded in messages is highlighted.
+func GetHighlightJSON() bool {
+	logHighlightJSONLock.RLock()
+	defer logHighlightJSONLock.RUnlock()
+	return logHighlightJSON
+}
+
+// embeddedJSON returns the span of the line holding the JSON the message ends
+// with, if any.
+func embeddedJSON(line []byte, message string) (int, int, bool) {
+	start := strings.IndexAny(message, "{[")
+	if start < 0 {
+		return 0, 0, false
+	}
+	object := strings.TrimRight(message[start:], "\r\n")
+	if !json.Valid([]byte(object)) {
+		return 0, 0, false
+	}
+	i := bytes.Index(line, []byte(object))
+	if i < 0 {
+		return 0, 0, false
+	}
+	return i, i + len(object), true
+}
+
+// paint appends the text to the buffer wrapped in the escape codes of the
+// colour.
+func paint(buffer *bytes.Buffer, colour *color.Color, text []byte) {
+	if len(text) == 0 {
+		return
+	}
+	colour.SetWriter(buffer)
+	buffer.Write(text)
+	colour.UnsetWriter(buffer)
+}
+
+// paintJSON appends the valid JSON to the buffer with its tokens highlighted,
+// and the punctuation and white space in the given colour.
+func paintJSON(buffer *bytes.Buffer, colour *color.Color, object []byte) {
+	plain := 0
+	for i := 0; i < len(object); {
+		var token *color.Color
+		j := i + 1
+		switch c := object[i]; {
+		case c == '"':
+			for object[j] != '"' {
+				if object[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j++
+			token = jsonColours.str
+			if rest := bytes.TrimLeft(object[j:], " \t\r\n"); len(rest) > 0 && rest[0] == ':' {
+				token = jsonColours.key
+			}
+		case c == '-' || (c >= '0' && c <= '9'):
+			for j < len(object) && strings.IndexByte("+-.eE0123456789", object[j]) >= 0 {
+				j++
+			}
+			token = jsonColours.number
+		case c == 't' || c == 'f' || c == 'n':
+			for j < len(object) && object[j] >= 'a' && object[j] <= 'z' {
+				j++
+			}
+			token = jsonColours.literal
+		default:
+			i++
+			continue
+		}
+		paint(buffer, colour, object[plain:i])
+		paint(buffer, token, object[i:j])
+		i, plain = j, j
+	}
+	paint(buffer, colour, object[plain:])
+}
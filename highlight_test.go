@@ -0,0 +1,93 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestAddHighlight(t *testing.T) {
+	defer ClearHighlights()
+	defer SetForceColorise(GetForceColorise())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, true)()
+
+	SetForceColorise(true)
+	if err := AddHighlight("(timeout"); err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+	if err := AddHighlight("timeout", color.FgRed, color.Bold); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	highlight := newColor(color.FgRed, color.Bold).Sprint("")
+	highlight = highlight[:strings.Index(highlight, "m")+1]
+
+	Infoln("request served")
+	if strings.HasPrefix(buffer.String(), highlight) {
+		t.Errorf("unexpected highlight in %q", buffer.String())
+	}
+	buffer.Reset()
+	Debugln("request failed", Str("error", "timeout"))
+	if !strings.HasPrefix(buffer.String(), highlight) || !strings.HasSuffix(buffer.String(), "\x1b[0m\n") {
+		t.Errorf("expected highlight in %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetForceColorise(false)
+	SetStream(buffer, true)
+	Debugln("request failed", Str("error", "timeout"))
+	if strings.Contains(buffer.String(), "\x1b[") {
+		t.Errorf("unexpected escape codes in %q", buffer.String())
+	}
+}
+
+func TestHighlightJSON(t *testing.T) {
+	defer SetHighlightJSON(GetHighlightJSON())
+	defer SetForceColorise(GetForceColorise())
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, true)()
+
+	SetForceColorise(true)
+	SetPrintCallerInfo(false)
+	SetPrintSourceInfo(SourceInfoNone)
+	code := func(attribute color.Attribute) string {
+		escape := newColor(attribute).Sprint("")
+		return escape[:strings.Index(escape, "m")+1]
+	}
+
+	Infof(`payload: {"id": 42, "name": "a \"b\"", "ok": true}`)
+	if strings.Contains(buffer.String(), code(color.FgCyan)) {
+		t.Errorf("unexpected JSON highlighting in %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetHighlightJSON(true)
+	Infof(`payload: {"id": 42, "name": "a \"b\"", "ok": true}`)
+	for _, expected := range []string{
+		code(color.FgCyan) + `"id"`,
+		code(color.FgYellow) + `42`,
+		code(color.FgGreen) + `"a \"b\""`,
+		code(color.FgMagenta) + `true`,
+	} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Errorf("expected %q in %q", expected, buffer.String())
+		}
+	}
+	if !strings.HasPrefix(buffer.String(), code(color.FgGreen)+"[I] ") || !strings.HasSuffix(buffer.String(), "\x1b[0m\n") {
+		t.Errorf("expected the rest of the line in the level colour in %q", buffer.String())
+	}
+
+	buffer.Reset()
+	Infof(`payload: {"id": 42,`)
+	if strings.Contains(buffer.String(), code(color.FgCyan)) {
+		t.Errorf("unexpected JSON highlighting of invalid JSON in %q", buffer.String())
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync"
+)
+
+// recordHook wraps a hook so that it can be told apart from the others when
+// it is removed, since functions are not comparable.
+type recordHook struct {
+	hook func(Record)
+}
+
+var (
+	logRecordHooks     []*recordHook
+	logRecordHooksLock sync.RWMutex
+)
+
+// AddRecordHook registers a function that receives the structured record of
+// each message that is written to the stream, e.g. to update metrics or to
+// forward messages to another system without parsing the formatted lines; it
+// returns a function that removes the hook. Hooks are invoked in the order
+// they were added, synchronously and after the message has been written, so
+// they should be quick; they must not modify the record's fields, which are
+// shared with the caller, nor log at a level that would invoke them again.
+func AddRecordHook(hook func(Record)) (remove func()) {
+	h := &recordHook{hook: hook}
+	defer changed()
+	logRecordHooksLock.Lock()
+	defer logRecordHooksLock.Unlock()
+	// copy on write, so that runHooks can iterate without holding the lock
+	hooks := make([]*recordHook, len(logRecordHooks), len(logRecordHooks)+1)
+	copy(hooks, logRecordHooks)
+	logRecordHooks = append(hooks, h)
+	return func() {
+		defer changed()
+		logRecordHooksLock.Lock()
+		defer logRecordHooksLock.Unlock()
+		hooks := make([]*recordHook, 0, len(logRecordHooks))
+		for _, other := range logRecordHooks {
+			if other != h {
+				hooks = append(hooks, other)
+			}
+		}
+		logRecordHooks = hooks
+	}
+}
+
+// runHooks passes the record to all the hooks registered when it was created.
+func runHooks(r *Record) {
+	for _, h := range r.config().hooks {
+		h.hook(*r)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAddRecordHook(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(InfoLevel)
+	SetPrintSourceInfo(SourceInfoShort)
+	records := []Record{}
+	remove := AddRecordHook(func(r Record) {
+		records = append(records, r)
+	})
+	Debugf("filtered out")
+	Warnf("disk %d%% full", 90, Int("disk", 1))
+	remove()
+	Errorf("after removal")
+
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	r := records[0]
+	if r.Level != WarnLevel || r.Message != "disk 90% full" || r.File != "hooks_test.go" || r.Time.IsZero() {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if len(r.Fields) != 1 || r.Fields[0].Key != "disk" || r.Fields[0].Value != 1 {
+		t.Errorf("unexpected fields %+v", r.Fields)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	logHostname     string
+	logHostnameOnce sync.Once
+)
+
+var (
+	logPrintHostname     bool
+	logPrintHostnameLock sync.RWMutex
+)
+
+// SetPrintHostname enables or disables the addition of the name of the host to
+// the log messages, so that lines from many hosts aggregated into the same file
+// can be told apart: it is written right after the time in text output (e.g.
+// "[D] <time> web-01 - message") and with the key set by SetHostKey in JSON
+// and logfmt output, while GELF output always carries it. The name is looked
+// up once, when the feature is first enabled; it is not written in test mode.
+func SetPrintHostname(enabled bool) {
+	defer changed()
+	if enabled {
+		hostname()
+	}
+	logPrintHostnameLock.Lock()
+	defer logPrintHostnameLock.Unlock()
+	logPrintHostname = enabled
+}
+
+// GetPrintHostname returns whether the name of the host is added to the log
+// messages.
+func GetPrintHostname() bool {
+	logPrintHostnameLock.RLock()
+	defer logPrintHostnameLock.RUnlock()
+	return logPrintHostname
+}
+
+// hostname returns the name of the host, as reported by the kernel, or
+// "localhost" if it cannot be determined; it is looked up only once.
+func hostname() string {
+	logHostnameOnce.Do(func() {
+		logHostname = "localhost"
+		if name, err := os.Hostname(); err == nil && name != "" {
+			logHostname = name
+		}
+	})
+	return logHostname
+}
@@ -0,0 +1,51 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPrintHostname(t *testing.T) {
+	defer SetPrintHostname(GetPrintHostname())
+	defer SetFormat(GetFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrintHostname(true)
+	Infof("served")
+	if !strings.Contains(buffer.String(), " "+hostname()+" - ") {
+		t.Errorf("expected hostname in %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	Infof("served")
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	if entry[GetHostKey()] != hostname() {
+		t.Errorf("expected hostname in %q", buffer.String())
+	}
+
+	buffer.Reset()
+	defer SetHostKey(GetHostKey())
+	SetHostKey("hostname")
+	Infof("served")
+	if !strings.Contains(buffer.String(), `"hostname":"`+hostname()+`"`) {
+		t.Errorf("expected hostname under the custom key in %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetPrintHostname(false)
+	Infof("served")
+	if strings.Contains(buffer.String(), `"`+GetHostKey()+`"`) {
+		t.Errorf("unexpected hostname in %q", buffer.String())
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPRequest writes an access log line for a request served with the given
+// status code in the given time, as fields named method, path, status,
+// duration and remote (the client's address), e.g.
+//
+//	request method=GET path=/index.html status=200 duration=1.2ms remote=10.0.0.1:51234
+//
+// in text output, or as the corresponding keys in structured output; the line
+// is written at error level for server errors (5xx) and at info level
+// otherwise, including client errors (4xx), unlike StatusLevel, provided the
+// level is enabled. The query string is not logged, since it may carry
+// credentials.
+func HTTPRequest(r *http.Request, status int, duration time.Duration) (int, error) {
+	level := InfoLevel
+	if status >= 500 {
+		level = ErrorLevel
+	}
+	if !isEnabled(level) || discarded(level) {
+		return 0, nil
+	}
+	return output(level, 1, "request", []Field{
+		Str("method", r.Method),
+		Str("path", r.URL.Path),
+		Int("status", status),
+		Dur("duration", duration),
+		Str("remote", r.RemoteAddr),
+	})
+}
+
+// StatusLevel returns the level suitable for logging a request served with the
+// given HTTP status code: WarnLevel for client errors (4xx), ErrorLevel for
+// server errors (5xx) and InfoLevel otherwise, e.g.
+//
+//	log.Logf(log.StatusLevel(status), "%s %s: %d", r.Method, r.URL.Path, status)
+//
+// It deliberately differs from HTTPRequest, which logs client errors at info
+// level: an access log records every request, and 4xx responses are part of
+// normal traffic there, while StatusLevel is meant for handlers that log only
+// the requests worth drawing attention to.
+func StatusLevel(code int) LogLevel {
+	switch {
+	case code >= 500:
+		return ErrorLevel
+	case code >= 400:
+		return WarnLevel
+	}
+	return InfoLevel
+}
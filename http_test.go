@@ -0,0 +1,61 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetFormat(GetFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	r := httptest.NewRequest("GET", "/index.html?token=secret", nil)
+	HTTPRequest(r, 200, 1200*time.Microsecond)
+	if !strings.HasPrefix(buffer.String(), "[I] ") || !strings.Contains(buffer.String(), "request method=GET path=/index.html status=200 duration=1.2ms remote=192.0.2.1:1234") {
+		t.Errorf("unexpected text output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	HTTPRequest(r, 503, time.Second)
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	if entry["level"] != "error" || entry["status"] != float64(503) || entry["path"] != "/index.html" {
+		t.Errorf("unexpected JSON output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetLevel(WarnLevel)
+	HTTPRequest(r, 404, time.Second)
+	if buffer.Len() != 0 {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+func TestStatusLevel(t *testing.T) {
+	for code, expected := range map[int]LogLevel{
+		101: InfoLevel,
+		200: InfoLevel,
+		304: InfoLevel,
+		404: WarnLevel,
+		499: WarnLevel,
+		500: ErrorLevel,
+		503: ErrorLevel,
+	} {
+		if level := StatusLevel(code); level != expected {
+			t.Errorf("expected %v for %d, got %v", expected, code, level)
+		}
+	}
+}
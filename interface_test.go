@@ -0,0 +1,29 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+// service is an example of code depending on Interface.
+func service(logger Interface) {
+	logger.Warnf("disk %d%% full", 90)
+}
+
+func TestDefault(t *testing.T) {
+	defer SetCallerStyle(GetCallerStyle())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetCallerStyle(CallerFuncOnly)
+	service(Default())
+	re := regexp.MustCompile(`^\[W\] .* - service: disk 90% full \(interface_test\.go:\d+\)\n$`)
+	if !re.MatchString(buffer.String()) {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
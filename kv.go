@@ -0,0 +1,119 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+// BadKey is the key of the values that are not preceded by a string key in
+// the arguments of the KV functions, as in log/slog.
+const BadKey = "!BADKEY"
+
+// keyValues converts alternating keys and values into fields: a string key is
+// paired with the following argument, a Field is taken as it is, and any
+// other argument, including a string with no argument following it, becomes
+// the value of a field with key BadKey.
+func keyValues(keyvals []interface{}) []Field {
+	if len(keyvals) == 0 {
+		return nil
+	}
+	fields := make([]Field, 0, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i++ {
+		switch arg := keyvals[i].(type) {
+		case Field:
+			fields = append(fields, arg)
+		case string:
+			if i+1 < len(keyvals) {
+				fields = append(fields, Field{Key: arg, Value: keyvals[i+1]})
+				i++
+			} else {
+				fields = append(fields, Field{Key: BadKey, Value: arg})
+			}
+		default:
+			fields = append(fields, Field{Key: BadKey, Value: arg})
+		}
+	}
+	return fields
+}
+
+// outputKV writes the message with the key/value pairs as fields, as output
+// does.
+func outputKV(level LogLevel, skip int, message string, keyvals []interface{}) (int, error) {
+	if discarded(level) {
+		return 0, nil
+	}
+	return output(level, skip+1, message, keyValues(keyvals))
+}
+
+// TraceKV writes a trace message to the current output stream, appending a new
+// line; the arguments after the message are alternating keys and values (see
+// InfoKV).
+func TraceKV(message string, keyvals ...interface{}) (int, error) {
+	if IsTrace() {
+		return outputKV(TraceLevel, 1, message, keyvals)
+	}
+	return 0, nil
+}
+
+// DebugKV writes a debug message to the current output stream, appending a new
+// line; the arguments after the message are alternating keys and values (see
+// InfoKV).
+func DebugKV(message string, keyvals ...interface{}) (int, error) {
+	if IsDebug() {
+		return outputKV(DebugLevel, 1, message, keyvals)
+	}
+	return 0, nil
+}
+
+// InfoKV writes an informational message to the current output stream,
+// appending a new line; the arguments after the message are alternating keys
+// and values, rendered as fields in any format, e.g.
+//
+//	log.InfoKV("request served", "method", "GET", "status", 200)
+//
+// and a value with no key gets BadKey as its key.
+func InfoKV(message string, keyvals ...interface{}) (int, error) {
+	if IsInfo() {
+		return outputKV(InfoLevel, 1, message, keyvals)
+	}
+	return 0, nil
+}
+
+// WarnKV writes a warning message to the current output stream, appending a new
+// line; the arguments after the message are alternating keys and values (see
+// InfoKV).
+func WarnKV(message string, keyvals ...interface{}) (int, error) {
+	if IsWarning() {
+		return outputKV(WarnLevel, 1, message, keyvals)
+	}
+	return 0, nil
+}
+
+// ErrorKV writes an error message to the current output stream, appending a new
+// line; the arguments after the message are alternating keys and values (see
+// InfoKV).
+func ErrorKV(message string, keyvals ...interface{}) (int, error) {
+	if IsError() {
+		return outputKV(ErrorLevel, 1, message, keyvals)
+	}
+	return 0, nil
+}
+
+// FatalKV writes an error message to the current output stream, appending a new
+// line; the arguments after the message are alternating keys and values (see
+// InfoKV).
+func FatalKV(message string, keyvals ...interface{}) (int, error) {
+	if IsFatal() {
+		return outputKV(FatalLevel, 1, message, keyvals)
+	}
+	return 0, nil
+}
+
+// PanicKV writes an error message to the current output stream, appending a
+// new line; the arguments after the message are alternating keys and values
+// (see InfoKV). Then it panics (see SetPanicWithMessage for the panic value).
+func PanicKV(message string, keyvals ...interface{}) (int, error) {
+	if IsPanic() {
+		output(PanicLevel, 1, message, keyValues(keyvals))
+	}
+	panic(panicValue(message))
+}
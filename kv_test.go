@@ -0,0 +1,42 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestKV(t *testing.T) {
+	defer SetFormat(GetFormat())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrintSourceInfo(SourceInfoNone)
+	InfoKV("request served", "method", "GET", "status", 200, Bool("cached", true))
+	if !strings.HasSuffix(buffer.String(), ": request served method=GET status=200 cached=true\n") {
+		t.Errorf("unexpected text output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	WarnKV("odd arguments", 42, "dangling")
+	if !strings.HasSuffix(buffer.String(), ": odd arguments !BADKEY=42 !BADKEY=dangling\n") {
+		t.Errorf("unexpected text output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	ErrorKV("100% failed", "status", 500)
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	if entry["msg"] != "100% failed" || entry["status"] != float64(500) {
+		t.Errorf("unexpected JSON output %q", buffer.String())
+	}
+}
@@ -13,14 +13,22 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mattn/go-colorable"
+	"github.com/mattn/go-isatty"
 
 	"github.com/fatih/color"
 )
 
-// LogLevel represents the log level.
+// LogLevel represents the log level. Levels are ordered by increasing severity,
+// i.e. by decreasing verbosity, from TraceLevel to PanicLevel, followed by
+// NoneLevel, and a message is logged if its level is at least the current one;
+// since the direction of the ordering is easily mistaken, code outside the
+// package should compare levels through Less, AtLeast and AtMost rather than
+// with the relational operators.
 type LogLevel int
 
 const (
@@ -86,6 +94,26 @@ func LevelFromString(s string) (LogLevel, error) {
 	}
 }
 
+// Less returns whether level a is less severe, i.e. more verbose, than level b,
+// e.g. Less(DebugLevel, ErrorLevel) is true.
+func Less(a, b LogLevel) bool {
+	return a < b
+}
+
+// AtLeast returns whether the log level is as severe as the given one, or more,
+// e.g. ErrorLevel.AtLeast(WarnLevel) is true; a message is logged if its level
+// is at least the current one.
+func (l LogLevel) AtLeast(level LogLevel) bool {
+	return !Less(l, level)
+}
+
+// AtMost returns whether the log level is as verbose as the given one, or more,
+// e.g. DebugLevel.AtMost(InfoLevel) is true; debug messages are logged if the
+// current level is at most DebugLevel.
+func (l LogLevel) AtMost(level LogLevel) bool {
+	return !Less(level, l)
+}
+
 // String returns a string representation of the log level for use in traces.
 func (l LogLevel) String() string {
 	switch l {
@@ -103,15 +131,72 @@ func (l LogLevel) String() string {
 		return "[F]"
 	case PanicLevel:
 		return "[P]"
+	case auditLevel:
+		return "[A]"
 	}
 	return ""
 }
 
-// logln is the prototype of log functions writing a line to a stream.
-type logln func(writer io.Writer, args ...interface{}) (int, error)
+// letter returns the single letter identifying the log level in text output.
+func (l LogLevel) letter() string {
+	return strings.Trim(l.String(), "[]")
+}
 
-// logf is the prototype of log functions writing a formatted output to a stream.
-type logf func(writer io.Writer, format string, args ...interface{}) (int, error)
+// levelColours holds the colour attributes associated with each log level.
+var levelColours = [...][]color.Attribute{
+	TraceLevel: {color.FgWhite},
+	DebugLevel: {color.FgWhite},
+	InfoLevel:  {color.FgGreen},
+	WarnLevel:  {color.FgYellow},
+	ErrorLevel: {color.FgRed},
+	FatalLevel: {color.FgBlue},
+	PanicLevel: {color.FgMagenta},
+	NoneLevel:  {},
+}
+
+// Color returns the colour the logger associates with the log level, so that
+// external renderers can match the logger's palette; the returned value is a
+// new instance and modifying it does not affect the logger.
+func (l LogLevel) Color() *color.Color {
+	if l < TraceLevel || l > NoneLevel {
+		return color.New()
+	}
+	return color.New(l.attributes()...)
+}
+
+// attributes returns the colour attributes of the log level, including bold
+// if it was set with SetLevelBold.
+func (l LogLevel) attributes() []color.Attribute {
+	attributes := levelColours[l]
+	if l < NoneLevel && logBoldLevels.Load()&(1<<uint(l)) != 0 {
+		attributes = append(attributes[:len(attributes):len(attributes)], color.Bold)
+	}
+	return attributes
+}
+
+// name returns the lowercase name of the log level, as used in structured
+// output.
+func (l LogLevel) name() string {
+	switch l {
+	case TraceLevel:
+		return "trace"
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warning"
+	case ErrorLevel:
+		return "error"
+	case FatalLevel:
+		return "fatal"
+	case PanicLevel:
+		return "panic"
+	case auditLevel:
+		return "audit"
+	}
+	return "none"
+}
 
 const (
 	// SourceInfoNone is the constant that specifies that no source file information
@@ -125,110 +210,393 @@ const (
 	SourceInfoLong
 )
 
+const (
+	// CallerShort is the constant that specifies that the calling function
+	// should be printed with its package name only (e.g. "pkg.Func").
+	CallerShort int8 = iota
+	// CallerFull is the constant that specifies that the calling function
+	// should be printed with its fully qualified package path (e.g.
+	// "github.com/org/repo/pkg.Func").
+	CallerFull
+	// CallerFuncOnly is the constant that specifies that the calling function
+	// should be printed without its package (e.g. "Func").
+	CallerFuncOnly
+)
+
+const (
+	// TimeDefault is the name of the default time format, with milliseconds
+	// (2006-01-02@15:04:05.000).
+	TimeDefault = "default"
+	// TimeRFC3339 is the name of the RFC 3339 time format (time.RFC3339).
+	TimeRFC3339 = "rfc3339"
+	// TimeRFC3339Nano is the name of the RFC 3339 time format with nanoseconds
+	// (time.RFC3339Nano).
+	TimeRFC3339Nano = "rfc3339nano"
+	// TimeKitchen is the name of the kitchen time format (time.Kitchen).
+	TimeKitchen = "kitchen"
+	// TimeUnixDate is the name of the Unix date time format (time.UnixDate).
+	TimeUnixDate = "unixdate"
+	// TimeDateTime is the name of the date and time format (time.DateTime).
+	TimeDateTime = "datetime"
+	// TimeStampMilli is the name of the time stamp format with milliseconds
+	// (time.StampMilli).
+	TimeStampMilli = "stampmilli"
+)
+
+// namedTimeFormats maps the names of time formats to their layouts.
+var namedTimeFormats = map[string]string{
+	TimeDefault:     "2006-01-02@15:04:05.000",
+	TimeRFC3339:     time.RFC3339,
+	TimeRFC3339Nano: time.RFC3339Nano,
+	TimeKitchen:     time.Kitchen,
+	TimeUnixDate:    time.UnixDate,
+	TimeDateTime:    time.DateTime,
+	TimeStampMilli:  time.StampMilli,
+}
+
 var (
-	logLevel               LogLevel
-	logLevelLock           sync.RWMutex
-	logStream              io.Writer
-	logStreamLock          sync.RWMutex
-	logTimeFormat          string
-	logTimeFormatLock      sync.RWMutex
-	logPrintSourceInfo     int8
-	logPrintSourceInfoLock sync.RWMutex
-	logPrintCallerInfo     bool
-	logPrintCallerInfoLock sync.RWMutex
-	logTracef              logf
-	logDebugf              logf
-	logInfof               logf
-	logWarnf               logf
-	logErrorf              logf
-	logFatalf              logf
-	logPanicf              logf
-	logTraceln             logln
-	logDebugln             logln
-	logInfoln              logln
-	logWarnln              logln
-	logErrorln             logln
-	logFatalln             logln
-	logPanicln             logln
+	logOwnLevel             atomic.Int32
+	logLevel                atomic.Pointer[atomic.Int32]
+	logLevelFilter          atomic.Uint32
+	logBoldLevels           atomic.Uint32
+	logStream               atomic.Pointer[streamState]
+	logForceColorise        bool
+	logForceColoriseLock    sync.RWMutex
+	logColoriseMinLevel     LogLevel
+	logColoriseMinLock      sync.RWMutex
+	logTimeFormat           string
+	logTimeFormatLock       sync.RWMutex
+	logTimeTruncate         time.Duration
+	logTimeTruncateLock     sync.RWMutex
+	logClock                func() time.Time
+	logClockLock            sync.RWMutex
+	logPrintSourceInfo      int8
+	logPrintSourceInfoLock  sync.RWMutex
+	logPrintCallerInfo      bool
+	logPrintCallerInfoLock  sync.RWMutex
+	logCallerInfoMinLevel   LogLevel
+	logCallerInfoMinLock    sync.RWMutex
+	logCallerStyle          int8
+	logCallerStyleLock      sync.RWMutex
+	logPrintPackage         bool
+	logPrintPackageLock     sync.RWMutex
+	logUnknownCaller        string
+	logUnknownCallerLock    sync.RWMutex
+	logPanicWithMessage     bool
+	logPanicWithMessageLock sync.RWMutex
+	logMaxMessageLength     int
+	logMaxMessageLengthLock sync.RWMutex
+	logTestMode             bool
+	logTestModeLock         sync.RWMutex
 )
 
 func init() {
+	logLevel.Store(&logOwnLevel)
 	SetLevel(DebugLevel)
 	SetStream(os.Stderr, true)
-	SetTimeFormat("2006-01-02@15:04:05.000")
+	SetTimeFormat(namedTimeFormats[TimeDefault])
+	SetClock(time.Now)
 	SetPrintCallerInfo(true)
+	SetCallerStyle(CallerShort)
+	SetPrintPackage(true)
+	SetUnknownCallerPlaceholder("???")
 	SetPrintSourceInfo(SourceInfoShort)
+	SetCallerInfoMinLevel(TraceLevel)
+	SetColoriseMinLevel(TraceLevel)
 }
 
-// SetLevel sets the log level for the application.
+// SetLevel sets the log level for the application; the level is stored
+// atomically, so that checking it on every log call requires no locking.
 func SetLevel(level LogLevel) {
-	logLevelLock.Lock()
-	defer logLevelLock.Unlock()
-	logLevel = level
+	logLevel.Load().Store(int32(level))
 }
 
-// GetLevel retur s the current log level.
+// GetLevel returns the current log level.
 func GetLevel() LogLevel {
-	logLevelLock.RLock()
-	defer logLevelLock.RUnlock()
-	return logLevel
+	return LogLevel(logLevel.Load().Load())
+}
+
+// BindLevel makes the logger read its level from the given variable, which is
+// owned by the caller, so that external code, e.g. an orchestration layer, can
+// change the verbosity by storing a LogLevel into it, without calling into the
+// package; from then on, the level is the variable's value, and SetLevel and
+// GetLevel write and read it. Passing nil unbinds the variable, and the logger
+// keeps its last value as its own level.
+func BindLevel(level *atomic.Int32) {
+	if level == nil {
+		logOwnLevel.Store(logLevel.Load().Load())
+		level = &logOwnLevel
+	}
+	logLevel.Store(level)
+}
+
+// Suppress silences the logger by setting the level to NoneLevel, e.g. around a
+// call into a noisy library, and returns a function that restores the previous
+// level; it is meant to be deferred, e.g.
+//
+//	defer log.Suppress()()
+//
+// Swapping the level is atomic, but the level is global: Suppress is meant for
+// scopes run by a single goroutine, since the messages of other goroutines are
+// silenced too, and overlapping scopes restore the levels in the order they
+// end.
+func Suppress() func() {
+	previous := LogLevel(logLevel.Load().Swap(int32(NoneLevel)))
+	return func() {
+		SetLevel(previous)
+	}
+}
+
+// SetLevelFilter restricts logging to messages whose level is exactly one of
+// the given levels, overriding the threshold set with SetLevel, e.g. to see
+// warnings but neither errors nor informational messages; calling it with no
+// levels clears the filter and returns to threshold mode.
+func SetLevelFilter(levels ...LogLevel) {
+	var mask uint32
+	for _, level := range levels {
+		if level >= TraceLevel && level < NoneLevel {
+			mask |= 1 << uint(level)
+		}
+	}
+	logLevelFilter.Store(mask)
+}
+
+// GetLevelFilter returns the levels messages are restricted to, or nil if no
+// filter is set.
+func GetLevelFilter() []LogLevel {
+	mask := logLevelFilter.Load()
+	if mask == 0 {
+		return nil
+	}
+	levels := []LogLevel{}
+	for level := TraceLevel; level < NoneLevel; level++ {
+		if mask&(1<<uint(level)) != 0 {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// isEnabled returns whether messages at the given level are logged, according
+// to the level filter if set, or to the log level otherwise.
+func isEnabled(level LogLevel) bool {
+	if mask := logLevelFilter.Load(); mask != 0 {
+		return level >= TraceLevel && level < NoneLevel && mask&(1<<uint(level)) != 0
+	}
+	return level.AtLeast(GetLevel())
+}
+
+// streamState bundles the stream with the state derived from it, so that it is
+// replaced as a whole by SetStream and a message being logged concurrently sees
+// either the old combination or the new one, never a mix of the two.
+type streamState struct {
+	// raw is the stream as passed to SetStream.
+	raw io.Writer
+	// colorise is whether colouring was requested for the stream.
+	colorise bool
+	// stream is the writer messages are actually written to, which wraps the
+	// raw stream if needed to render colours.
+	stream io.Writer
+	// colours holds the colours of the levels, or nil if the stream is not
+	// colourised.
+	colours []*color.Color
+	// discard is whether the stream is io.Discard.
+	discard bool
+	// terminal is the stream if it is a terminal, or nil.
+	terminal *os.File
 }
 
 // SetStream sets the stream to write messages to; if the colorise flag is set,
 // the logger will wrap the stream so it always produces properly coloured output
-// messages; this might be less appropriate when writing to a file.
+// messages; colouring is only applied when the stream is a terminal, so that
+// redirected output is not polluted with escape codes, unless it is forced on
+// with SetForceColorise.
 func SetStream(stream io.Writer, colorise bool) {
-	logStreamLock.Lock()
-	defer logStreamLock.Unlock()
-	if stream, ok := stream.(*os.File); colorise && ok {
-		logStream = colorable.NewColorable(stream)
-		logTracef = color.New(color.FgWhite).Fprintf
-		logDebugf = color.New(color.FgWhite).Fprintf
-		logInfof = color.New(color.FgGreen).Fprintf
-		logWarnf = color.New(color.FgYellow).Fprintf
-		logErrorf = color.New(color.FgRed).Fprintf
-		logFatalf = color.New(color.FgBlue).Fprintf
-		logPanicf = color.New(color.FgMagenta).Fprintf
-		logTraceln = color.New(color.FgWhite).Fprintln
-		logDebugln = color.New(color.FgWhite).Fprintln
-		logInfoln = color.New(color.FgGreen).Fprintln
-		logWarnln = color.New(color.FgYellow).Fprintln
-		logErrorln = color.New(color.FgRed).Fprintln
-		logFatalln = color.New(color.FgBlue).Fprintln
-		logPanicln = color.New(color.FgMagenta).Fprintln
-	} else {
-		logStream = stream
-		logTracef = fmt.Fprintf
-		logDebugf = fmt.Fprintf
-		logInfof = fmt.Fprintf
-		logWarnf = fmt.Fprintf
-		logErrorf = fmt.Fprintf
-		logFatalf = fmt.Fprintf
-		logPanicf = fmt.Fprintf
-		logTraceln = fmt.Fprintln
-		logDebugln = fmt.Fprintln
-		logInfoln = fmt.Fprintln
-		logWarnln = fmt.Fprintln
-		logErrorln = fmt.Fprintln
-		logFatalln = fmt.Fprintln
-		logPanicln = fmt.Fprintln
+	defer changed()
+	// records and lines batched for the previous stream go there
+	flushFormatter()
+	flushBatch()
+	logStream.Store(newStreamState(stream, colorise))
+}
+
+// newStreamState returns the state of the given stream, colourised as
+// requested, according to the current settings.
+func newStreamState(stream io.Writer, colorise bool) *streamState {
+	state := &streamState{
+		raw:      stream,
+		colorise: colorise,
+		stream:   stream,
+		discard:  stream == io.Discard,
+	}
+	if isTerminal(stream) {
+		state.terminal = stream.(*os.File)
+	}
+	if colorise && (GetForceColorise() || isTerminal(stream)) {
+		if file, ok := stream.(*os.File); ok {
+			state.stream = colorable.NewColorable(file)
+		}
+		state.colours = make([]*color.Color, NoneLevel)
+		for level := TraceLevel; level < NoneLevel; level++ {
+			state.colours[level] = newColor(level.attributes()...)
+		}
+	}
+	return state
+}
+
+// updateStream rebuilds the state of the current stream, whether colourised as
+// the function returns, e.g. after the colours have changed; the new state
+// replaces the one it was built from only if it is still current, and it is
+// rebuilt otherwise, so that a concurrent SetStream is never reverted.
+func updateStream(colorise func(state *streamState) bool) {
+	defer changed()
+	for {
+		state := logStream.Load()
+		if logStream.CompareAndSwap(state, newStreamState(state.raw, colorise(state))) {
+			return
+		}
+	}
+}
+
+// sameColorise keeps the colouring requested for the stream, for updateStream.
+func sameColorise(state *streamState) bool {
+	return state.colorise
+}
+
+// SetForceColorise forces colouring on streams for which it was requested
+// even when they are not terminals, e.g. when the output is piped into a pager
+// that understands escape codes; the current stream and the streams added with
+// AddStream are updated accordingly.
+func SetForceColorise(enabled bool) {
+	logForceColoriseLock.Lock()
+	logForceColorise = enabled
+	logForceColoriseLock.Unlock()
+	updateStream(sameColorise)
+	updateStreams()
+}
+
+// SetLevelBold sets whether messages at the given level are bold, in addition
+// to their colour, on colourised streams, e.g. to make errors and panics stand
+// out; no level is bold by default. The current stream and the streams added
+// with AddStream are updated accordingly.
+func SetLevelBold(level LogLevel, bold bool) {
+	if level < TraceLevel || level >= NoneLevel {
+		return
+	}
+	for {
+		mask := logBoldLevels.Load()
+		updated := mask &^ (1 << uint(level))
+		if bold {
+			updated = mask | 1<<uint(level)
+		}
+		if logBoldLevels.CompareAndSwap(mask, updated) {
+			break
+		}
 	}
+	updateStream(sameColorise)
+	updateStreams()
+}
+
+// GetLevelBold returns whether messages at the given level are bold.
+func GetLevelBold(level LogLevel) bool {
+	return level >= TraceLevel && level < NoneLevel && logBoldLevels.Load()&(1<<uint(level)) != 0
+}
+
+// SetColoriseMinLevel sets the minimum level of the messages that are coloured
+// on colourised streams, e.g. WarnLevel to draw the eye to warnings and errors
+// while leaving debug and informational messages plain; the default is
+// TraceLevel, i.e. all messages are coloured.
+func SetColoriseMinLevel(level LogLevel) {
+	defer changed()
+	logColoriseMinLock.Lock()
+	defer logColoriseMinLock.Unlock()
+	logColoriseMinLevel = level
+}
+
+// GetColoriseMinLevel returns the minimum level of the messages that are
+// coloured on colourised streams.
+func GetColoriseMinLevel() LogLevel {
+	logColoriseMinLock.RLock()
+	defer logColoriseMinLock.RUnlock()
+	return logColoriseMinLevel
+}
+
+// GetForceColorise returns whether colouring is forced on streams that are not
+// terminals.
+func GetForceColorise() bool {
+	logForceColoriseLock.RLock()
+	defer logForceColoriseLock.RUnlock()
+	return logForceColorise
+}
+
+// isTerminal returns whether the given stream is a terminal.
+func isTerminal(stream io.Writer) bool {
+	if file, ok := stream.(*os.File); ok {
+		return isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd())
+	}
+	return false
+}
+
+// newColor returns a colour for the given attributes, with colouring enabled
+// regardless of whether the standard output is a terminal, since the logger
+// decides on its own stream.
+func newColor(attributes ...color.Attribute) *color.Color {
+	c := color.New(attributes...)
+	c.EnableColor()
+	return c
 }
 
 // GetStream returns the current log stream.
 func GetStream() io.Writer {
-	logStreamLock.RLock()
-	defer logStreamLock.RUnlock()
-	return logStream
+	return logStream.Load().stream
+}
+
+// Sync commits the messages written so far to stable storage, if the current
+// stream supports it (as *os.File does, through its Sync method); it is a
+// no-op otherwise. Note that syncing standard output or standard error may
+// return an error on some platforms.
+func Sync() error {
+	if syncer, ok := logStream.Load().raw.(interface{ Sync() error }); ok {
+		return syncer.Sync()
+	}
+	return nil
+}
+
+// WithWriter temporarily redirects log messages to the given stream, with the
+// same semantics as SetStream, and returns a function that restores the
+// previous stream; it is meant to be deferred, e.g.
+//
+//	defer log.WithWriter(buffer, false)()
+//
+// so that the previous stream is restored even if the code in between panics.
+func WithWriter(stream io.Writer, colorise bool) func() {
+	previous := logStream.Load()
+	SetStream(stream, colorise)
+	return func() {
+		SetStream(previous.raw, previous.colorise)
+	}
 }
 
 // SetTimeFormat sets the format for log messages time.
 func SetTimeFormat(format string) {
+	defer changed()
 	logTimeFormatLock.Lock()
 	defer logTimeFormatLock.Unlock()
 	logTimeFormat = format
 }
 
+// SetTimeFormatNamed sets the format for log messages time by name, which is
+// less error prone than spelling out a layout and more readable in
+// configuration files; the name (e.g. TimeRFC3339 or "rfc3339") is parsed in a
+// lenient way, and an error is returned if it is unknown.
+func SetTimeFormatNamed(name string) error {
+	format, ok := namedTimeFormats[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return fmt.Errorf("unknown time format name: %q", name)
+	}
+	SetTimeFormat(format)
+	return nil
+}
+
 // GetTimeFormat returns the current format of log messages time.
 func GetTimeFormat() string {
 	logTimeFormatLock.RLock()
@@ -236,10 +604,53 @@ func GetTimeFormat() string {
 	return logTimeFormat
 }
 
+// SetTimeTruncate sets the precision of the time of the messages: times are
+// rounded down to a multiple of the given duration before being formatted, in
+// every output format, e.g. to print whole seconds regardless of the layout or
+// to bucket records into coarse intervals for time-series systems. A duration
+// of 0 or less, which is the default, leaves times as they are.
+func SetTimeTruncate(d time.Duration) {
+	defer changed()
+	logTimeTruncateLock.Lock()
+	defer logTimeTruncateLock.Unlock()
+	logTimeTruncate = d
+}
+
+// GetTimeTruncate returns the duration times are rounded down to, or 0 or less
+// if they are not.
+func GetTimeTruncate() time.Duration {
+	logTimeTruncateLock.RLock()
+	defer logTimeTruncateLock.RUnlock()
+	return logTimeTruncate
+}
+
+// SetClock sets the function the logger reads the current time from, e.g. a
+// controllable clock in tests exercising time-dependent behaviour, which is
+// then deterministic; it is used for the time of messages and by Timeit, while
+// the delays of batched writes are still measured with real timers. Passing
+// nil restores time.Now, which is the default.
+func SetClock(clock func() time.Time) {
+	defer changed()
+	if clock == nil {
+		clock = time.Now
+	}
+	logClockLock.Lock()
+	defer logClockLock.Unlock()
+	logClock = clock
+}
+
+// GetClock returns the function the logger reads the current time from.
+func GetClock() func() time.Time {
+	logClockLock.RLock()
+	defer logClockLock.RUnlock()
+	return logClock
+}
+
 // SetPrintCallerInfo enables or disables the automatic addition of the calling
 // function (with package) to the log messages. NOTE: enabling this feature can
 // have severe impacts on performances since it uses reflection at runtime.
 func SetPrintCallerInfo(enabled bool) {
+	defer changed()
 	logPrintCallerInfoLock.Lock()
 	defer logPrintCallerInfoLock.Unlock()
 	logPrintCallerInfo = enabled
@@ -253,16 +664,100 @@ func GetPrintCallerInfo() bool {
 	return logPrintCallerInfo
 }
 
+// SetCallerStyle sets how the calling function is printed when the automatic
+// addition of caller info is enabled; use one among CallerShort, CallerFull
+// and CallerFuncOnly here.
+func SetCallerStyle(value int8) {
+	defer changed()
+	logCallerStyleLock.Lock()
+	defer logCallerStyleLock.Unlock()
+	logCallerStyle = value
+}
+
+// GetCallerStyle returns how the calling function is printed when the automatic
+// addition of caller info is enabled.
+func GetCallerStyle() int8 {
+	logCallerStyleLock.RLock()
+	defer logCallerStyleLock.RUnlock()
+	return logCallerStyle
+}
+
+// SetPrintPackage sets whether the package of the calling function is printed
+// along with its name when the automatic addition of caller info is enabled;
+// if not, only the function name (e.g. "Func", or "Type.Method") is printed,
+// as with CallerFuncOnly, whatever the caller style. This is useful when the
+// package is obvious from the context and shorter lines are preferable.
+func SetPrintPackage(enabled bool) {
+	defer changed()
+	logPrintPackageLock.Lock()
+	defer logPrintPackageLock.Unlock()
+	logPrintPackage = enabled
+}
+
+// GetPrintPackage returns whether the package of the calling function is
+// printed along with its name.
+func GetPrintPackage() bool {
+	logPrintPackageLock.RLock()
+	defer logPrintPackageLock.RUnlock()
+	return logPrintPackage
+}
+
+// SetUnknownCallerPlaceholder sets the text that replaces the calling function
+// and the source file and line in text and logfmt output when they cannot be
+// determined, e.g. for code without debug information; the default is "???".
+// In JSON output, unknown values are always written as null.
+func SetUnknownCallerPlaceholder(placeholder string) {
+	defer changed()
+	logUnknownCallerLock.Lock()
+	defer logUnknownCallerLock.Unlock()
+	logUnknownCaller = placeholder
+}
+
+// GetUnknownCallerPlaceholder returns the text that replaces the calling
+// function and the source file and line when they cannot be determined.
+func GetUnknownCallerPlaceholder() string {
+	logUnknownCallerLock.RLock()
+	defer logUnknownCallerLock.RUnlock()
+	return logUnknownCaller
+}
+
 // SetPrintSourceInfo enables or disables the automatic addition of the source
 // and line number info to the log messages; use one among SourceFileNone,
 // SourceFileShort and SourceFileLong here. NOTE: enabling this feature can
 // have severe impacts on performances since it uses reflection at runtime.
 func SetPrintSourceInfo(value int8) {
+	defer changed()
 	logPrintSourceInfoLock.Lock()
 	defer logPrintSourceInfoLock.Unlock()
 	logPrintSourceInfo = value
 }
 
+// SetPrintSourceInfoByName is like SetPrintSourceInfo, but it takes the name
+// of the setting, i.e. "none", "short" or "long", so that it can be driven
+// from configuration files and environment variables; the name is parsed in a
+// lenient way, and an error is returned if it is unknown.
+func SetPrintSourceInfoByName(s string) error {
+	value, err := sourceInfoFromName(s)
+	if err != nil {
+		return err
+	}
+	SetPrintSourceInfo(value)
+	return nil
+}
+
+// sourceInfoFromName parses the name of a source info setting.
+func sourceInfoFromName(s string) (int8, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "none", "off", "":
+		return SourceInfoNone, nil
+	case "short":
+		return SourceInfoShort, nil
+	case "long", "full":
+		return SourceInfoLong, nil
+	}
+	return SourceInfoNone, fmt.Errorf("unparseable source info setting: %q", s)
+}
+
 // GetPrintSourceInfo returns whether the automatic addition of the source and
 // line number info to the log messages is enabled, and whether the file name
 // will be printed in short or long form.
@@ -272,52 +767,175 @@ func GetPrintSourceInfo() int8 {
 	return logPrintSourceInfo
 }
 
+// SetCallerInfoMinLevel sets the minimum level of the messages for which the
+// caller and source info are looked up and printed, if enabled; e.g. with
+// WarnLevel, the expensive lookup only runs for warnings and more severe
+// messages, and not for the high-volume debug and trace lines. The default is
+// TraceLevel, i.e. all messages; audit records are not affected.
+func SetCallerInfoMinLevel(level LogLevel) {
+	defer changed()
+	logCallerInfoMinLock.Lock()
+	defer logCallerInfoMinLock.Unlock()
+	logCallerInfoMinLevel = level
+}
+
+// GetCallerInfoMinLevel returns the minimum level of the messages for which
+// the caller and source info are printed.
+func GetCallerInfoMinLevel() LogLevel {
+	logCallerInfoMinLock.RLock()
+	defer logCallerInfoMinLock.RUnlock()
+	return logCallerInfoMinLevel
+}
+
+// TruncatedMarker is appended to messages that are truncated because they
+// exceed the maximum message length.
+const TruncatedMarker = "…(truncated)"
+
+// SetMaxMessageLength sets the maximum length in bytes of the formatted
+// message, excluding the level, time and runtime information and the fields;
+// longer messages are truncated, without splitting multi-byte UTF-8 sequences,
+// and TruncatedMarker is appended to them. This protects the logging pipeline
+// from pathological payloads, such as a whole HTTP body. A value of 0 or less,
+// which is the default, means no limit.
+func SetMaxMessageLength(n int) {
+	defer changed()
+	logMaxMessageLengthLock.Lock()
+	defer logMaxMessageLengthLock.Unlock()
+	logMaxMessageLength = n
+}
+
+// GetMaxMessageLength returns the maximum length in bytes of the formatted
+// message, or 0 or less if there is no limit.
+func GetMaxMessageLength() int {
+	logMaxMessageLengthLock.RLock()
+	defer logMaxMessageLengthLock.RUnlock()
+	return logMaxMessageLength
+}
+
+// SetTestMode enables or disables a mode for unit tests, where the output is
+// deterministic, e.g. to compare it against golden files: the time, the
+// calling function and the source info are left out, in every format, and no
+// colours are applied; the other settings are left untouched, so that
+// disabling the mode restores the previous output.
+func SetTestMode(enabled bool) {
+	defer changed()
+	logTestModeLock.Lock()
+	defer logTestModeLock.Unlock()
+	logTestMode = enabled
+}
+
+// GetTestMode returns whether the output is deterministic for unit tests.
+func GetTestMode() bool {
+	logTestModeLock.RLock()
+	defer logTestModeLock.RUnlock()
+	return logTestMode
+}
+
+// truncate shortens the message to at most n bytes, plus the marker, backing
+// off to the start of a UTF-8 sequence if needed; a trailing newline is kept.
+func truncate(message string, n int) string {
+	if n <= 0 || len(message) <= n {
+		return message
+	}
+	newline := strings.HasSuffix(message, "\n")
+	for n > 0 && !utf8.RuneStart(message[n]) {
+		n--
+	}
+	message = message[:n] + TruncatedMarker
+	if newline {
+		message += "\n"
+	}
+	return message
+}
+
+// SetPanicWithMessage sets whether Panicf and Panicln panic with the bare
+// message, without level, time and runtime information, instead of a generic
+// "unrecoverable error"; this way a recovered panic can be logged again without
+// being decorated twice. The decorated message is written to the stream anyway.
+func SetPanicWithMessage(enabled bool) {
+	logPanicWithMessageLock.Lock()
+	defer logPanicWithMessageLock.Unlock()
+	logPanicWithMessage = enabled
+}
+
+// GetPanicWithMessage returns whether Panicf and Panicln panic with the bare
+// message.
+func GetPanicWithMessage() bool {
+	logPanicWithMessageLock.RLock()
+	defer logPanicWithMessageLock.RUnlock()
+	return logPanicWithMessage
+}
+
+// panicValue returns the value Panicf and Panicln panic with.
+func panicValue(message string) string {
+	if GetPanicWithMessage() {
+		return strings.TrimRight(message, "\r\n")
+	}
+	return "unrecoverable error"
+}
+
 // IsTrace returns whether the trace (TraceLevel) log elevel is enabled.
 func IsTrace() bool {
-	return GetLevel() <= TraceLevel
+	return isEnabled(TraceLevel)
 }
 
 // IsDebug returns whether the debug (DebugLevel) log elevel is enabled.
 func IsDebug() bool {
-	return GetLevel() <= DebugLevel
+	return isEnabled(DebugLevel)
 }
 
 // IsInfo returns whether the informational (InfoLevel) log elevel is enabled.
 func IsInfo() bool {
-	return GetLevel() <= InfoLevel
+	return isEnabled(InfoLevel)
 }
 
 // IsWarning returns whether the warning (WarnLevel) log elevel is enabled.
 func IsWarning() bool {
-	return GetLevel() <= WarnLevel
+	return isEnabled(WarnLevel)
 }
 
 // IsError returns whether the error (ErrorLevel) log elevel is enabled.
 func IsError() bool {
-	return GetLevel() <= ErrorLevel
+	return isEnabled(ErrorLevel)
 }
 
 // IsFatal returns whether the fatal (FatalLevel) log elevel is enabled.
 func IsFatal() bool {
-	return GetLevel() <= FatalLevel
+	return isEnabled(FatalLevel)
 }
 
 // IsPanic returns whether the panic (PanicLevel) log elevel is enabled.
 func IsPanic() bool {
-	return GetLevel() <= PanicLevel
+	return isEnabled(PanicLevel)
 }
 
-// IsDisabled returns whether the log is disabled.
+// IsDisabled returns whether the log is disabled, i.e. no messages are logged
+// because the log level is NoneLevel and there is no level filter.
 func IsDisabled() bool {
-	return GetLevel() <= NoneLevel
+	return logLevelFilter.Load() == 0 && GetLevel().AtLeast(NoneLevel)
+}
+
+// WouldLog returns whether a message at the given level, logged from the call
+// site of WouldLog, would actually be written somewhere, taking into account
+// the log level, the level filter, the muted sources and whether the stream
+// discards everything; unlike IsDebug and its siblings, it lets the caller skip
+// building an expensive payload whenever it would be thrown away, e.g.
+//
+//	if log.WouldLog(log.DebugLevel) {
+//		log.Debugf("state: %s", dump())
+//	}
+func WouldLog(level LogLevel) bool {
+	if level < TraceLevel || level >= NoneLevel || !isEnabled(level) || discarded(level) {
+		return false
+	}
+	return !isMuted(current().muted, 1)
 }
 
 // Traceln writes a trace message to the current output stream, appending a new
 // line.
 func Traceln(args ...interface{}) (int, error) {
 	if IsTrace() {
-		args = prepareArgs(DebugLevel, args...)
-		return logTraceln(GetStream(), args...)
+		return outputln(TraceLevel, 1, args)
 	}
 	return 0, nil
 }
@@ -326,8 +944,7 @@ func Traceln(args ...interface{}) (int, error) {
 // line.
 func Debugln(args ...interface{}) (int, error) {
 	if IsDebug() {
-		args = prepareArgs(DebugLevel, args...)
-		return logDebugln(GetStream(), args...)
+		return outputln(DebugLevel, 1, args)
 	}
 	return 0, nil
 }
@@ -336,8 +953,7 @@ func Debugln(args ...interface{}) (int, error) {
 // appending a new line.
 func Infoln(args ...interface{}) (int, error) {
 	if IsInfo() {
-		args = prepareArgs(InfoLevel, args...)
-		return logInfoln(GetStream(), args...)
+		return outputln(InfoLevel, 1, args)
 	}
 	return 0, nil
 }
@@ -346,8 +962,7 @@ func Infoln(args ...interface{}) (int, error) {
 // line.
 func Warnln(args ...interface{}) (int, error) {
 	if IsWarning() {
-		args = prepareArgs(WarnLevel, args...)
-		return logWarnln(GetStream(), args...)
+		return outputln(WarnLevel, 1, args)
 	}
 	return 0, nil
 }
@@ -356,8 +971,7 @@ func Warnln(args ...interface{}) (int, error) {
 // line.
 func Errorln(args ...interface{}) (int, error) {
 	if IsError() {
-		args = prepareArgs(ErrorLevel, args...)
-		return logErrorln(GetStream(), args...)
+		return outputln(ErrorLevel, 1, args)
 	}
 	return 0, nil
 }
@@ -366,30 +980,25 @@ func Errorln(args ...interface{}) (int, error) {
 // line.
 func Fatalln(args ...interface{}) (int, error) {
 	if IsFatal() {
-		args = prepareArgs(FatalLevel, args...)
-		logFatalln(GetStream(), args...)
+		return outputln(FatalLevel, 1, args)
 	}
 	return 0, nil
 }
 
 // Panicln writes an error message to the current output stream, appending a new
-// line; then it panics.
+// line; then it panics (see SetPanicWithMessage for the panic value).
 func Panicln(args ...interface{}) (int, error) {
+	message, fields := sprintln(args)
 	if IsPanic() {
-		args = prepareArgs(PanicLevel, args...)
-		logPanicln(GetStream(), args...)
+		output(PanicLevel, 1, message, fields)
 	}
-	panic("unrecoverable error")
+	panic(panicValue(message))
 }
 
 // Tracef writes a trace message to the current output stream, appending a new line.
 func Tracef(format string, args ...interface{}) (int, error) {
 	if IsTrace() {
-		format, args = prepareFormatAndArgs(TraceLevel, format, args...)
-		if !strings.HasSuffix(format, "\n") && !strings.HasSuffix(format, "\r") {
-			format = format + "\n"
-		}
-		return logTracef(GetStream(), format, args...)
+		return outputf(TraceLevel, 1, format, args)
 	}
 	return 0, nil
 }
@@ -397,11 +1006,7 @@ func Tracef(format string, args ...interface{}) (int, error) {
 // Debugf writes a debug message to the current output stream, appending a new line.
 func Debugf(format string, args ...interface{}) (int, error) {
 	if IsDebug() {
-		format, args = prepareFormatAndArgs(DebugLevel, format, args...)
-		if !strings.HasSuffix(format, "\n") && !strings.HasSuffix(format, "\r") {
-			format = format + "\n"
-		}
-		return logDebugf(GetStream(), format, args...)
+		return outputf(DebugLevel, 1, format, args)
 	}
 	return 0, nil
 }
@@ -410,11 +1015,7 @@ func Debugf(format string, args ...interface{}) (int, error) {
 // appending a new line.
 func Infof(format string, args ...interface{}) (int, error) {
 	if IsInfo() {
-		format, args = prepareFormatAndArgs(InfoLevel, format, args...)
-		if !strings.HasSuffix(format, "\n") && !strings.HasSuffix(format, "\r") {
-			format = format + "\n"
-		}
-		return logInfof(GetStream(), format, args...)
+		return outputf(InfoLevel, 1, format, args)
 	}
 	return 0, nil
 }
@@ -422,11 +1023,7 @@ func Infof(format string, args ...interface{}) (int, error) {
 // Warnf writes a warning message to the current output stream, appending a new line.
 func Warnf(format string, args ...interface{}) (int, error) {
 	if IsWarning() {
-		format, args = prepareFormatAndArgs(WarnLevel, format, args...)
-		if !strings.HasSuffix(format, "\n") && !strings.HasSuffix(format, "\r") {
-			format = format + "\n"
-		}
-		return logWarnf(GetStream(), format, args...)
+		return outputf(WarnLevel, 1, format, args)
 	}
 	return 0, nil
 }
@@ -435,11 +1032,7 @@ func Warnf(format string, args ...interface{}) (int, error) {
 // line.
 func Errorf(format string, args ...interface{}) (int, error) {
 	if IsError() {
-		format, args = prepareFormatAndArgs(ErrorLevel, format, args...)
-		if !strings.HasSuffix(format, "\n") && !strings.HasSuffix(format, "\r") {
-			format = format + "\n"
-		}
-		return logErrorf(GetStream(), format, args...)
+		return outputf(ErrorLevel, 1, format, args)
 	}
 	return 0, nil
 }
@@ -448,26 +1041,102 @@ func Errorf(format string, args ...interface{}) (int, error) {
 // line.
 func Fatalf(format string, args ...interface{}) (int, error) {
 	if IsFatal() {
-		format, args = prepareFormatAndArgs(FatalLevel, format, args...)
-		if !strings.HasSuffix(format, "\n") && !strings.HasSuffix(format, "\r") {
-			format = format + "\n"
-		}
-		logFatalf(GetStream(), format, args...)
+		return outputf(FatalLevel, 1, format, args)
 	}
 	return 0, nil
 }
 
 // Panicf writes an error message to the current output stream, appending a new
-// line; then it panics.
+// line; then it panics (see SetPanicWithMessage for the panic value).
 func Panicf(format string, args ...interface{}) (int, error) {
+	message, fields := sprintf(format, args)
 	if IsPanic() {
-		format, args = prepareFormatAndArgs(PanicLevel, format, args...)
-		if !strings.HasSuffix(format, "\n") && !strings.HasSuffix(format, "\r") {
-			format = format + "\n"
+		output(PanicLevel, 1, message, fields)
+	}
+	panic(panicValue(message))
+}
+
+// Logf writes a message at the given level to the current output stream,
+// appending a new line, exactly as the function for that level would, e.g.
+// Logf(WarnLevel, ...) is equivalent to Warnf(...); in particular, it panics at
+// PanicLevel. Messages at NoneLevel are discarded.
+func Logf(level LogLevel, format string, args ...interface{}) (int, error) {
+	return logf(level, 1, format, args)
+}
+
+// Logln writes a message at the given level to the current output stream,
+// appending a new line, exactly as the function for that level would, e.g.
+// Logln(WarnLevel, ...) is equivalent to Warnln(...); in particular, it panics
+// at PanicLevel. Messages at NoneLevel are discarded.
+func Logln(level LogLevel, args ...interface{}) (int, error) {
+	return logln(level, 1, args)
+}
+
+// logf implements Logf; skip is the number of stack frames to ascend from the
+// caller of logf to reach the call site.
+func logf(level LogLevel, skip int, format string, args []interface{}) (int, error) {
+	switch {
+	case level == PanicLevel:
+		message, fields := sprintf(format, args)
+		if IsPanic() {
+			output(PanicLevel, skip+1, message, fields)
+		}
+		panic(panicValue(message))
+	case level >= TraceLevel && level < NoneLevel && isEnabled(level):
+		return outputf(level, skip+1, format, args)
+	}
+	return 0, nil
+}
+
+// logln implements Logln; skip is the number of stack frames to ascend from
+// the caller of logln to reach the call site.
+func logln(level LogLevel, skip int, args []interface{}) (int, error) {
+	switch {
+	case level == PanicLevel:
+		message, fields := sprintln(args)
+		if IsPanic() {
+			output(PanicLevel, skip+1, message, fields)
 		}
-		logPanicf(GetStream(), format, args...)
+		panic(panicValue(message))
+	case level >= TraceLevel && level < NoneLevel && isEnabled(level):
+		return outputln(level, skip+1, args)
+	}
+	return 0, nil
+}
+
+// LogAt writes a message at the given level to the current output stream,
+// appending a new line, exactly as Logf would, but with the given time instead
+// of the current time; it is useful to re-emit past events, e.g. when
+// replaying or importing historical data, preserving their timestamps.
+func LogAt(t time.Time, level LogLevel, format string, args ...interface{}) (int, error) {
+	if level != PanicLevel && (level < TraceLevel || level >= NoneLevel || !isEnabled(level) || discarded(level)) {
+		return 0, nil
+	}
+	message, fields := sprintf(format, args)
+	return emit(t, level, 1, nil, message, fields)
+}
+
+// LogWithCaller writes a message at the given level to the current output
+// stream, appending a new line, exactly as Logf would, but with the given
+// function, source file and line as its call site instead of those of the
+// caller, which are not looked up; it is useful to forward events that carry
+// their original provenance, e.g. when consuming them from a message queue.
+// The call site is used verbatim, whether caller and source info are printed
+// is still controlled by the usual settings, and muted sources are matched
+// against it (see MuteSource).
+func LogWithCaller(level LogLevel, file string, line int, fn string, format string, args ...interface{}) (int, error) {
+	if level != PanicLevel && (level < TraceLevel || level >= NoneLevel || !isEnabled(level) || discarded(level)) {
+		return 0, nil
 	}
-	panic("unrecoverable error")
+	message, fields := sprintf(format, args)
+	return emit(time.Time{}, level, 1, &callSite{function: fn, file: file, line: line}, message, fields)
+}
+
+// callSite is a call site that is given rather than looked up in the stack.
+type callSite struct {
+	function string
+	file     string
+	line     int
 }
 
 // Println is a raw version of the debug functions; it tries to interpret the
@@ -479,138 +1148,246 @@ func Println(args ...interface{}) (int, error) {
 		if value, ok := args[0].(string); ok {
 			switch {
 			case strings.HasPrefix(value, "[T]"):
-				return Traceln(args[1:]...)
+				return logln(TraceLevel, 1, args[1:])
 			case strings.HasPrefix(value, "[D]"):
-				return Debugln(args[1:]...)
+				return logln(DebugLevel, 1, args[1:])
 			case strings.HasPrefix(value, "[I]"):
-				return Infoln(args[1:]...)
+				return logln(InfoLevel, 1, args[1:])
 			case strings.HasPrefix(value, "[W]"):
-				return Warnln(args[1:]...)
+				return logln(WarnLevel, 1, args[1:])
 			case strings.HasPrefix(value, "[E]"):
-				return Errorln(args[1:]...)
+				return logln(ErrorLevel, 1, args[1:])
 			case strings.HasPrefix(value, "[F]"):
-				return Fatalln(args[1:]...)
+				return logln(FatalLevel, 1, args[1:])
 			case strings.HasPrefix(value, "[P]"):
-				return Panicln(args[1:]...)
+				return logln(PanicLevel, 1, args[1:])
 			}
 		}
 	}
-	return fmt.Fprintln(GetStream(), args...)
+	return Rawln(args...)
 }
 
+// levelTagPattern matches the level tag, and the following white space, at the
+// beginning of the formats passed to Printf.
+var levelTagPattern = regexp.MustCompile(`^\[(T|D|I|W|E|F|P)\]\s`)
+
 // Printf is a raw version of the debug functions; it tries to interpret the
 // message by checking if it starts with anything like "[D]" or "[W]"; if so, it
 // delegates to the corresponding logging function, otherwise it just prints to
 // the log stream as is, with no additional formatting.
 func Printf(format string, args ...interface{}) (int, error) {
-	re := regexp.MustCompile(`^\[(T|D|I|W|E|F|P)\]\s`)
 	switch {
 	case strings.HasPrefix(format, "[T]"):
-		return Tracef(re.ReplaceAllString(format, ""), args...)
+		return logf(TraceLevel, 1, levelTagPattern.ReplaceAllString(format, ""), args)
 	case strings.HasPrefix(format, "[D]"):
-		return Debugf(re.ReplaceAllString(format, ""), args...)
+		return logf(DebugLevel, 1, levelTagPattern.ReplaceAllString(format, ""), args)
 	case strings.HasPrefix(format, "[I]"):
-		return Infof(re.ReplaceAllString(format, ""), args...)
+		return logf(InfoLevel, 1, levelTagPattern.ReplaceAllString(format, ""), args)
 	case strings.HasPrefix(format, "[W]"):
-		return Warnf(re.ReplaceAllString(format, ""), args...)
+		return logf(WarnLevel, 1, levelTagPattern.ReplaceAllString(format, ""), args)
 	case strings.HasPrefix(format, "[E]"):
-		return Errorf(re.ReplaceAllString(format, ""), args...)
+		return logf(ErrorLevel, 1, levelTagPattern.ReplaceAllString(format, ""), args)
 	case strings.HasPrefix(format, "[F]"):
-		return Fatalf(re.ReplaceAllString(format, ""), args...)
+		return logf(FatalLevel, 1, levelTagPattern.ReplaceAllString(format, ""), args)
 	case strings.HasPrefix(format, "[P]"):
-		return Panicf(re.ReplaceAllString(format, ""), args...)
+		return logf(PanicLevel, 1, levelTagPattern.ReplaceAllString(format, ""), args)
 	}
+	return Rawf(format, args...)
+}
+
+// Rawln writes its arguments to the current log stream as fmt.Println does,
+// with no level, time or runtime information; unlike Println, it never
+// interprets a leading "[D]" or the like, so it is the way to print a line that
+// happens to start with one. The line ending is honoured.
+func Rawln(args ...interface{}) (int, error) {
+	flushPending()
+	if GetLineEnding() == LineCRLF {
+		line := fmt.Sprintln(args...)
+		return io.WriteString(GetStream(), line[:len(line)-1]+"\r\n")
+	}
+	return fmt.Fprintln(GetStream(), args...)
+}
+
+// Rawf writes the formatted message to the current log stream as fmt.Printf
+// does, with no level, time or runtime information and no new line appended;
+// unlike Printf, it never interprets a leading "[D]" or the like.
+func Rawf(format string, args ...interface{}) (int, error) {
+	flushPending()
 	return fmt.Fprintf(GetStream(), format, args...)
 }
 
-// prepareFormatAndArgs prepares the format and args array for logf, depending
-// on the active runtime logging options (e.g. caller function, source file and
-// line number).
-func prepareFormatAndArgs(level LogLevel, format string, args ...interface{}) (string, []interface{}) {
-
-	leadFormat := "%s %s - "
-	tailFormat := ""
-	leadArgs := []interface{}{level.String(), time.Now().Format(GetTimeFormat())}
-	tailArgs := []interface{}{}
-
-	if GetPrintCallerInfo() || GetPrintSourceInfo() > 0 {
-		var fun, file string
-		var line int
-		pc, file, line, ok := runtime.Caller(2)
-		if !ok {
-			fun = "<unknown>"
-			file = "???"
-			line = -1
-		} else {
-			if GetPrintCallerInfo() {
-				f := runtime.FuncForPC(pc)
-				if f == nil {
-					fun = "<unknown>"
-				} else {
-					fun = f.Name()
-				}
-				fun = fun[strings.LastIndex(fun, "/")+1:]
-				leadFormat = leadFormat + "%s: "
-				leadArgs = append(leadArgs, fun)
-			}
-			switch GetPrintSourceInfo() {
-			case SourceInfoShort:
-				file = file[strings.LastIndex(file, "/")+1:]
-				fallthrough
-				// tailFormat = " (%s:%d)"
-				// tailArgs = append(tailArgs, []interface{}{file, line}...)
-			case SourceInfoLong:
-				tailFormat = " (%s:%d)"
-				tailArgs = append(tailArgs, []interface{}{file, line}...)
-				format = strings.TrimSuffix(format, "\n")
-			default:
-			}
+// Format returns the line that would be written for a message at the given
+// level, complete with level, time and runtime information, without writing
+// it to the stream and regardless of the current log level; it is useful to
+// reuse the exact text of a message elsewhere, e.g. in an error response.
+func Format(level LogLevel, format string, args ...interface{}) string {
+	message, fields := sprintf(format, args)
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	render(buffer, newRecord(current(), level, 1, nil, time.Time{}, message, fields))
+	return buffer.String()
+}
+
+// Output writes an informational message to the current output stream, like
+// the standard library's log.Output, so that this package can be used by code
+// that expects it; calldepth is the number of stack frames to skip when
+// looking up the call site, where 1 is the caller of Output. A new line is
+// appended if the message does not end with one.
+func Output(calldepth int, s string) error {
+	if IsInfo() {
+		_, err := output(InfoLevel, calldepth, s, nil)
+		return err
+	}
+	return nil
+}
+
+// output renders the message at the given level according to the current
+// format and writes it to the current stream, coloured if needed; skip is the
+// number of stack frames to ascend from the caller of output to reach the call
+// site whose information is reported.
+func output(level LogLevel, skip int, message string, fields []Field) (int, error) {
+	return outputAt(time.Time{}, level, skip+1, nil, message, fields)
+}
+
+// emit writes the message at the given time and level, if enabled, exactly as
+// the function for that level would; in particular, it panics at PanicLevel.
+// The call site is looked up unless it is given.
+func emit(t time.Time, level LogLevel, skip int, site *callSite, message string, fields []Field) (int, error) {
+	var n int
+	var err error
+	if isEnabled(level) {
+		n, err = outputAt(t, level, skip+1, site, message, fields)
+	}
+	if level == PanicLevel {
+		panic(panicValue(message))
+	}
+	return n, err
+}
+
+// outputAt is like output, but the message is logged at the given time, or at
+// the current time if it is zero, and from the given call site, or from the one
+// skip frames up the stack if nil.
+func outputAt(t time.Time, level LogLevel, skip int, site *callSite, message string, fields []Field) (int, error) {
+	if discarded(level) {
+		return 0, nil
+	}
+	// read the settings once, so that the message is consistent and logging
+	// goroutines do not contend on the locks of the single settings
+	s := current()
+	if (site != nil && mutes(s.muted, site.function, site.file)) || (site == nil && isMuted(s.muted, skip+1)) {
+		return 0, nil
+	}
+	count(level)
+	r := newRecord(s, level, skip+1, site, t, message, fields)
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+	n, err := writeRecord(s, r, buffer)
+	if err != nil {
+		n, err = writeFailed(r, n, err)
+	} else if level == FatalLevel || level == PanicLevel {
+		if err = flushFormatter(); err == nil {
+			err = flushBatch()
 		}
 	}
-	format = leadFormat + format + tailFormat
-	args = append(leadArgs, append(args, tailArgs...)...)
-	return format, args
-}
-
-// prepareArgs prepares the aray of args for logln , depending on the active
-// runtime logging options (e.g. caller function, source file and line number);
-// it is similar to prepareFormatAndArgs but logln does not require a format.
-func prepareArgs(level LogLevel, args ...interface{}) []interface{} {
-
-	list := []interface{}{fmt.Sprintf("%s %s -", level.String(), time.Now().Format(GetTimeFormat()))}
-	if GetPrintCallerInfo() || GetPrintSourceInfo() > 0 {
-		var fun, file string
-		var line int
-		pc, file, line, ok := runtime.Caller(2)
-		if !ok {
-			fun = "<unknown>"
-			file = "???"
-			line = -1
-		} else {
-			if GetPrintCallerInfo() {
-				f := runtime.FuncForPC(pc)
-				if f == nil {
-					fun = "<unknown>"
-				} else {
-					fun = f.Name()
-				}
-				fun = fun[strings.LastIndex(fun, "/")+1:]
-				list = append(list, fmt.Sprintf("%s:", fun))
-			}
-			switch GetPrintSourceInfo() {
-			case SourceInfoShort:
-				file = file[strings.LastIndex(file, "/")+1:]
-				fallthrough
-			case SourceInfoLong:
-				last := strings.TrimSuffix(fmt.Sprintf("%v", args[len(args)-1]), "\n")
-				args = append(args[:len(args)-1], last)
-				args = append(args, fmt.Sprintf("(%s:%d)", file, line))
-			default:
-			}
+	writeStreams(r)
+	if err == nil && level >= s.flushOnLevel && level < NoneLevel {
+		err = Flush()
+	}
+	runHooks(r)
+	if level == FatalLevel || level == PanicLevel {
+		toStderr(r)
+		crash(r)
+	}
+	return n, err
+}
+
+// outputf formats the message and writes it as output does.
+func outputf(level LogLevel, skip int, format string, args []interface{}) (int, error) {
+	if discarded(level) {
+		return 0, nil
+	}
+	message, fields := sprintf(format, args)
+	return output(level, skip+1, message, fields)
+}
+
+// outputln formats the message as fmt.Sprintln does and writes it as output
+// does.
+func outputln(level LogLevel, skip int, args []interface{}) (int, error) {
+	if discarded(level) {
+		return 0, nil
+	}
+	message, fields := sprintln(args)
+	return output(level, skip+1, message, fields)
+}
+
+// discarded returns whether messages at the given level are going to be thrown
+// away, because the stream is io.Discard and there are no added streams to
+// write to, no record hooks to notify nor a crash file to write to, in which
+// case they need not even be formatted; nothing is recorded, not even the
+// counts.
+func discarded(level LogLevel) bool {
+	s := current()
+	if !s.discard || len(s.hooks) > 0 || len(s.streams) > 0 {
+		return false
+	}
+	return level < FatalLevel || s.crashFile == ""
+}
+
+// sprintf formats the message according to the format; any trailing Field or
+// Fields arguments are not used for formatting and are returned separately,
+// followed by the chains of the wrapped errors among the arguments, if they
+// are expanded.
+func sprintf(format string, args []interface{}) (string, []Field) {
+	args, fields := splitFields(args)
+	return fmt.Sprintf(format, args...), append(fields, errorChains(args)...)
+}
+
+// sprintln formats its arguments as fmt.Sprintln does, without the trailing
+// newline; any trailing Field or Fields arguments are returned separately, as
+// with sprintf. It takes a slice so that vet does not treat the Xxxln functions
+// as Println wrappers, since they deliberately tolerate a trailing newline.
+func sprintln(args []interface{}) (string, []Field) {
+	args, fields := splitFields(args)
+	message := fmt.Sprintln(args...)
+	return message[:len(message)-1], append(fields, errorChains(args)...)
+}
+
+// callerInfo returns the name of the calling function, the source file and
+// the line number of the call site, skip frames up the stack from the caller of
+// callerInfo; the file name is shortened if the source info mode in the
+// settings requires it.
+// The name and the file are empty, and the line is 0, if they are unknown.
+func callerInfo(s *settings, skip int) (string, string, int) {
+	fun, file, line := "", "", 0
+	if pc, f, l, ok := runtime.Caller(skip + 1); ok {
+		file, line = f, l
+		if f := runtime.FuncForPC(pc); f != nil {
+			fun = callerName(s, f.Name())
 		}
 	}
-	args = append(list, args...)
-	return args
+	if s.printSourceInfo == SourceInfoShort {
+		file = file[strings.LastIndex(file, "/")+1:]
+	}
+	return fun, file, line
+}
+
+// callerName formats the fully qualified name of a function according to the
+// current caller style and whether the package is printed.
+func callerName(s *settings, name string) string {
+	style := s.callerStyle
+	if !s.printPackage {
+		style = CallerFuncOnly
+	}
+	switch style {
+	case CallerFull:
+		return name
+	case CallerFuncOnly:
+		name = name[strings.LastIndex(name, "/")+1:]
+		// skip the package name, which cannot contain dots
+		return name[strings.Index(name, ".")+1:]
+	default:
+		return name[strings.LastIndex(name, "/")+1:]
+	}
 }
 
 // ToJSON converts an object into pretty-printed JSON format.
@@ -620,3 +1397,15 @@ func ToJSON(object interface{}) string {
 	}
 	return ""
 }
+
+// TraceDump writes a trace message with the label followed by the object in
+// pretty-printed JSON format (see ToJSON), e.g. "request: {...}"; unlike
+// Tracef("request: %s", ToJSON(request)), the object is only marshalled if
+// trace messages are enabled and actually written, so that dumps cost nothing
+// otherwise.
+func TraceDump(label string, object interface{}) (int, error) {
+	if !IsTrace() || discarded(TraceLevel) {
+		return 0, nil
+	}
+	return output(TraceLevel, 1, label+": "+ToJSON(object), nil)
+}
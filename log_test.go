@@ -5,8 +5,17 @@
 package log
 
 import (
+	"bytes"
+	"io"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/fatih/color"
 )
 
 func TestLog(t *testing.T) {
@@ -62,3 +71,787 @@ func TestLog(t *testing.T) {
 	Fatalln("fatal message with newline", "no colour")
 
 }
+
+func TestCallerName(t *testing.T) {
+	defer SetCallerStyle(GetCallerStyle())
+
+	name := "github.com/dihedron/go-log.(*Entry).Infof"
+	tests := []struct {
+		style    int8
+		expected string
+	}{
+		{CallerShort, "go-log.(*Entry).Infof"},
+		{CallerFull, "github.com/dihedron/go-log.(*Entry).Infof"},
+		{CallerFuncOnly, "(*Entry).Infof"},
+	}
+	for _, test := range tests {
+		SetCallerStyle(test.style)
+		if actual := callerName(current(), name); actual != test.expected {
+			t.Errorf("style %d: expected %q, got %q", test.style, test.expected, actual)
+		}
+	}
+
+	defer SetPrintPackage(GetPrintPackage())
+	SetPrintPackage(false)
+	for _, test := range tests {
+		SetCallerStyle(test.style)
+		if actual := callerName(current(), name); actual != "(*Entry).Infof" {
+			t.Errorf("style %d without package: expected %q, got %q", test.style, "(*Entry).Infof", actual)
+		}
+	}
+}
+
+func TestSourceInfoLayout(t *testing.T) {
+	defer SetStream(os.Stderr, true)
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetTimeFormat(GetTimeFormat())
+	defer SetLevel(GetLevel())
+
+	SetLevel(TraceLevel)
+	SetTimeFormat("15:04:05")
+	SetPrintCallerInfo(true)
+
+	tests := []struct {
+		mode int8
+		file string
+	}{
+		{SourceInfoShort, `log_test\.go`},
+		{SourceInfoLong, `/.+/log_test\.go`},
+	}
+	for _, test := range tests {
+		SetPrintSourceInfo(test.mode)
+		re := regexp.MustCompile(`^\[I\] \d\d:\d\d:\d\d - go-log\.TestSourceInfoLayout\.func\d+: message \(` + test.file + `:\d+\)\n$`)
+		for _, log := range []func(){
+			func() { Infof("message") },
+			func() { Infof("message\n") },
+			func() { Infof("%s", "message") },
+			func() { Infoln("message") },
+			func() { Infoln("message\n") },
+		} {
+			buffer := &bytes.Buffer{}
+			SetStream(buffer, false)
+			log()
+			if !re.MatchString(buffer.String()) {
+				t.Errorf("mode %d: unexpected layout %q", test.mode, buffer.String())
+			}
+		}
+	}
+}
+
+func TestWithWriter(t *testing.T) {
+	outer := &bytes.Buffer{}
+	inner := &bytes.Buffer{}
+	defer WithWriter(outer, false)()
+
+	func() {
+		defer WithWriter(inner, false)()
+		Errorln("inner message")
+	}()
+	Errorln("outer message")
+
+	if !strings.Contains(inner.String(), "inner message") || strings.Contains(inner.String(), "outer message") {
+		t.Errorf("unexpected inner output %q", inner.String())
+	}
+	if !strings.Contains(outer.String(), "outer message") || strings.Contains(outer.String(), "inner message") {
+		t.Errorf("unexpected outer output %q", outer.String())
+	}
+}
+
+func TestColoriseOnlyTerminals(t *testing.T) {
+	defer SetForceColorise(GetForceColorise())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, true)()
+
+	Errorln("not a terminal")
+	if strings.Contains(buffer.String(), "\x1b[") {
+		t.Errorf("unexpected escape codes in %q", buffer.String())
+	}
+	buffer.Reset()
+	SetForceColorise(true)
+	Errorln("forced")
+	if !strings.Contains(buffer.String(), "\x1b[31m") {
+		t.Errorf("expected escape codes in %q", buffer.String())
+	}
+}
+
+func TestFormat(t *testing.T) {
+	defer SetLevel(GetLevel())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(NoneLevel)
+	line := Format(ErrorLevel, "request %d failed", 42)
+	if buffer.Len() != 0 {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+	if !strings.HasPrefix(line, "[E] ") || !strings.Contains(line, "go-log.TestFormat: request 42 failed (log_test.go:") {
+		t.Errorf("unexpected line %q", line)
+	}
+}
+
+func TestPanicWithMessage(t *testing.T) {
+	defer SetPanicWithMessage(GetPanicWithMessage())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	tests := []struct {
+		enabled  bool
+		expected string
+	}{
+		{false, "unrecoverable error"},
+		{true, "cannot continue: 42"},
+	}
+	for _, test := range tests {
+		SetPanicWithMessage(test.enabled)
+		func() {
+			defer func() {
+				if r := recover(); r != test.expected {
+					t.Errorf("expected panic value %q, got %q", test.expected, r)
+				}
+			}()
+			Panicf("cannot continue: %d\n", 42)
+		}()
+		if !strings.HasPrefix(buffer.String(), "[P] ") {
+			t.Errorf("unexpected output %q", buffer.String())
+		}
+		buffer.Reset()
+	}
+}
+
+func TestSync(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "sync-*.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	defer WithWriter(file, false)()
+
+	Infoln("synced message")
+	if err := Sync(); err != nil {
+		t.Errorf("unexpected error syncing file: %v", err)
+	}
+
+	defer WithWriter(&bytes.Buffer{}, false)()
+	if err := Sync(); err != nil {
+		t.Errorf("unexpected error syncing buffer: %v", err)
+	}
+}
+
+func TestLevelColor(t *testing.T) {
+	if !ErrorLevel.Color().Equals(color.New(color.FgRed)) {
+		t.Errorf("unexpected colour for error level")
+	}
+	c := WarnLevel.Color()
+	c.Add(color.Bold)
+	if !WarnLevel.Color().Equals(color.New(color.FgYellow)) {
+		t.Errorf("modifying the returned colour affected the logger")
+	}
+}
+
+func TestWouldLog(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetLevelFilter()
+	defer UnmuteSources()
+	defer WithWriter(&bytes.Buffer{}, false)()
+
+	SetLevel(InfoLevel)
+	if WouldLog(DebugLevel) || !WouldLog(InfoLevel) || WouldLog(NoneLevel) {
+		t.Errorf("unexpected result for the log level")
+	}
+	SetLevelFilter(WarnLevel)
+	if WouldLog(InfoLevel) || !WouldLog(WarnLevel) || WouldLog(ErrorLevel) {
+		t.Errorf("unexpected result for the level filter")
+	}
+	SetLevelFilter()
+	MuteSource(`log_test\.go$`)
+	if WouldLog(ErrorLevel) {
+		t.Errorf("unexpected result for a muted source")
+	}
+	UnmuteSources()
+	defer WithWriter(io.Discard, false)()
+	if WouldLog(ErrorLevel) {
+		t.Errorf("unexpected result for a discarded stream")
+	}
+}
+
+func TestSetLevelBold(t *testing.T) {
+	defer SetLevelBold(ErrorLevel, false)
+	defer SetForceColorise(GetForceColorise())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, true)()
+
+	SetForceColorise(true)
+	SetLevelBold(ErrorLevel, true)
+	if !GetLevelBold(ErrorLevel) || GetLevelBold(WarnLevel) {
+		t.Errorf("unexpected bold levels")
+	}
+	if !ErrorLevel.Color().Equals(color.New(color.FgRed, color.Bold)) {
+		t.Errorf("expected bold red for error level")
+	}
+	Errorln("failed")
+	if !strings.HasPrefix(buffer.String(), "\x1b[31;1m") {
+		t.Errorf("expected bold red output, got %q", buffer.String())
+	}
+	SetLevelBold(ErrorLevel, false)
+	if !ErrorLevel.Color().Equals(color.New(color.FgRed)) {
+		t.Errorf("expected plain red for error level")
+	}
+}
+
+func TestLevelOrder(t *testing.T) {
+	if !Less(DebugLevel, ErrorLevel) || Less(ErrorLevel, DebugLevel) || Less(InfoLevel, InfoLevel) {
+		t.Errorf("unexpected result of Less")
+	}
+	if !ErrorLevel.AtLeast(WarnLevel) || !WarnLevel.AtLeast(WarnLevel) || DebugLevel.AtLeast(InfoLevel) {
+		t.Errorf("unexpected result of AtLeast")
+	}
+	if !DebugLevel.AtMost(InfoLevel) || !InfoLevel.AtMost(InfoLevel) || PanicLevel.AtMost(FatalLevel) {
+		t.Errorf("unexpected result of AtMost")
+	}
+}
+
+func TestIsDisabled(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetLevelFilter()
+
+	SetLevel(TraceLevel)
+	if IsDisabled() {
+		t.Errorf("expected the log to be enabled at trace level")
+	}
+	SetLevel(NoneLevel)
+	if !IsDisabled() {
+		t.Errorf("expected the log to be disabled at none level")
+	}
+	SetLevelFilter(ErrorLevel)
+	if IsDisabled() {
+		t.Errorf("expected the level filter to enable the log")
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetLevelFilter()
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(ErrorLevel)
+	SetLevelFilter(WarnLevel, TraceLevel)
+	Tracef("trace")
+	Infof("info")
+	Warnf("warning")
+	Errorf("error")
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "[T]") || !strings.HasPrefix(lines[1], "[W]") {
+		t.Errorf("unexpected filtered output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetLevelFilter()
+	if GetLevelFilter() != nil {
+		t.Errorf("expected filter to be cleared")
+	}
+	Warnf("warning")
+	Errorf("error")
+	if !strings.HasPrefix(buffer.String(), "[E]") || strings.Count(buffer.String(), "\n") != 1 {
+		t.Errorf("unexpected threshold output %q", buffer.String())
+	}
+}
+
+func TestSetTimeFormatNamed(t *testing.T) {
+	defer SetTimeFormat(GetTimeFormat())
+
+	for name, expected := range map[string]string{
+		TimeRFC3339:     time.RFC3339,
+		" RFC3339Nano ": time.RFC3339Nano,
+		"Kitchen":       time.Kitchen,
+		TimeUnixDate:    time.UnixDate,
+	} {
+		if err := SetTimeFormatNamed(name); err != nil {
+			t.Errorf("unexpected error for %q: %v", name, err)
+		}
+		if GetTimeFormat() != expected {
+			t.Errorf("expected %q for %q, got %q", expected, name, GetTimeFormat())
+		}
+	}
+	if err := SetTimeFormatNamed("2006-01-02"); err == nil {
+		t.Errorf("expected error for unknown name")
+	}
+}
+
+func TestBindLevel(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer BindLevel(nil)
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	var level atomic.Int32
+	level.Store(int32(WarnLevel))
+	BindLevel(&level)
+	Infoln("filtered out")
+	if GetLevel() != WarnLevel || buffer.Len() != 0 {
+		t.Errorf("unexpected level %v or output %q", GetLevel(), buffer.String())
+	}
+	level.Store(int32(InfoLevel))
+	Infoln("written")
+	if !strings.Contains(buffer.String(), "written") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+	SetLevel(ErrorLevel)
+	if LogLevel(level.Load()) != ErrorLevel {
+		t.Errorf("expected SetLevel to write through, got %v", LogLevel(level.Load()))
+	}
+	BindLevel(nil)
+	level.Store(int32(TraceLevel))
+	if GetLevel() != ErrorLevel {
+		t.Errorf("expected the last bound level to be kept, got %v", GetLevel())
+	}
+}
+
+func TestSuppress(t *testing.T) {
+	defer SetLevel(GetLevel())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(InfoLevel)
+	restore := Suppress()
+	Errorln("silenced")
+	if GetLevel() != NoneLevel || buffer.Len() != 0 {
+		t.Errorf("unexpected level %v or output %q", GetLevel(), buffer.String())
+	}
+	restore()
+	Infoln("written")
+	if GetLevel() != InfoLevel || !strings.Contains(buffer.String(), "written") {
+		t.Errorf("unexpected level %v or output %q", GetLevel(), buffer.String())
+	}
+}
+
+// dumpCounter counts the times it is marshalled.
+type dumpCounter struct {
+	calls *int
+}
+
+func (d dumpCounter) MarshalJSON() ([]byte, error) {
+	*d.calls++
+	return []byte(`{"id":1}`), nil
+}
+
+func TestTraceDump(t *testing.T) {
+	defer SetLevel(GetLevel())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	calls := 0
+	SetLevel(DebugLevel)
+	TraceDump("request", dumpCounter{&calls})
+	if calls != 0 || buffer.Len() != 0 {
+		t.Errorf("expected no marshalling, got %d calls and %q", calls, buffer.String())
+	}
+	SetLevel(TraceLevel)
+	TraceDump("request", dumpCounter{&calls})
+	if calls != 1 || !strings.Contains(buffer.String(), "TestTraceDump: request: {\n  \"id\": 1\n}") {
+		t.Errorf("unexpected output %q after %d calls", buffer.String(), calls)
+	}
+}
+
+func TestSetTimeTruncate(t *testing.T) {
+	defer SetTimeFormat(GetTimeFormat())
+	defer SetTimeTruncate(GetTimeTruncate())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetTimeFormat(time.RFC3339Nano)
+	at := time.Date(2024, 3, 1, 10, 20, 30, 456789000, time.UTC)
+	LogAt(at, InfoLevel, "precise")
+	if !strings.HasPrefix(buffer.String(), "[I] 2024-03-01T10:20:30.456789Z ") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetTimeTruncate(time.Minute)
+	LogAt(at, InfoLevel, "coarse")
+	if !strings.HasPrefix(buffer.String(), "[I] 2024-03-01T10:20:00Z ") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	defer SetClock(nil)
+	defer SetTimeFormat(GetTimeFormat())
+	defer SetCallerStyle(GetCallerStyle())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	now := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	SetClock(func() time.Time {
+		now = now.Add(1500 * time.Microsecond)
+		return now
+	})
+	SetTimeFormat("15:04:05.000")
+	SetCallerStyle(CallerFuncOnly)
+	done := Timeit("step")
+	done()
+	if !strings.HasPrefix(buffer.String(), "[D] 04:05:06.004 - TestSetClock: step elapsed=1.5ms ") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+
+	SetClock(nil)
+	if GetClock()().Year() == 2001 {
+		t.Errorf("expected the real clock to be restored")
+	}
+}
+
+func TestSetTestMode(t *testing.T) {
+	defer SetTestMode(GetTestMode())
+	defer SetFormat(GetFormat())
+	defer SetForceColorise(GetForceColorise())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, true)()
+
+	SetForceColorise(true)
+	SetTestMode(true)
+	Warnf("disk almost full", Int("percent", 95))
+	if buffer.String() != "[W] - disk almost full percent=95\n" {
+		t.Errorf("unexpected text output %q", buffer.String())
+	}
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	Warnf("disk almost full")
+	if buffer.String() != `{"level":"warning","msg":"disk almost full"}`+"\n" {
+		t.Errorf("unexpected JSON output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetTestMode(false)
+	Warnf("disk almost full")
+	if !strings.Contains(buffer.String(), `"time":`) || !strings.Contains(buffer.String(), `"caller":`) {
+		t.Errorf("unexpected JSON output %q", buffer.String())
+	}
+}
+
+func TestSetStreamConcurrent(t *testing.T) {
+	defer SetForceColorise(GetForceColorise())
+	plain, coloured := &countingWriter{}, &countingWriter{}
+	defer WithWriter(plain, false)()
+
+	SetForceColorise(true)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				Infoln("message")
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		SetStream(coloured, true)
+		SetStream(plain, false)
+	}
+	wg.Wait()
+
+	lines, _ := plain.state()
+	if strings.Contains(lines, "\x1b[") {
+		t.Errorf("escape codes written to the plain stream")
+	}
+	n := strings.Count(lines, "\n")
+	lines, _ = coloured.state()
+	for _, line := range strings.SplitAfter(lines, "\n") {
+		if line != "" && !strings.HasPrefix(line, "\x1b[") {
+			t.Errorf("plain line %q written to the coloured stream", line)
+		}
+	}
+	if n += strings.Count(lines, "\n"); n != 800 {
+		t.Errorf("expected 800 lines, got %d", n)
+	}
+}
+
+func TestUpdateStreamConcurrent(t *testing.T) {
+	defer SetForceColorise(GetForceColorise())
+	defer SetLevelBold(ErrorLevel, false)
+	first, second := &countingWriter{}, &countingWriter{}
+	defer WithWriter(first, true)()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			SetForceColorise(i%2 == 0)
+			SetLevelBold(ErrorLevel, i%2 == 0)
+		}
+	}()
+	SetStream(second, true)
+	wg.Wait()
+	if state := logStream.Load(); state.raw != second || !state.colorise {
+		t.Errorf("expected the stream set concurrently to be kept, got %T", state.raw)
+	}
+}
+
+// stdlibWrapper logs like a wrapper around the standard library's Output.
+func stdlibWrapper(s string) error {
+	return Output(2, s)
+}
+
+func TestOutput(t *testing.T) {
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	defer SetCallerStyle(GetCallerStyle())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrintSourceInfo(SourceInfoShort)
+	SetCallerStyle(CallerFuncOnly)
+	if err := stdlibWrapper("through the wrapper"); err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`^\[I\] .* - TestOutput: through the wrapper \(log_test\.go:\d+\)\n$`)
+	if !re.MatchString(buffer.String()) {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+func TestRaw(t *testing.T) {
+	defer SetLineEnding(GetLineEnding())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	Rawln("[D]", "not a debug message")
+	Rawf("[W] %d%%", 100)
+	if buffer.String() != "[D] not a debug message\n[W] 100%" {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+	buffer.Reset()
+	SetLineEnding(LineCRLF)
+	Rawln("[E]")
+	if buffer.String() != "[E]\r\n" {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+func TestLogfLogln(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetCallerStyle(GetCallerStyle())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(InfoLevel)
+	SetCallerStyle(CallerFuncOnly)
+	Logf(WarnLevel, "disk %d%% full", 90)
+	Logln(DebugLevel, "filtered out")
+	Logln(ErrorLevel, "connection", "refused")
+	Logf(NoneLevel, "discarded")
+	Println("[E]", "via prefix")
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %q", len(lines), buffer.String())
+	}
+	for i, re := range []string{
+		`^\[W\] .* - TestLogfLogln: disk 90% full`,
+		`^\[E\] .* - TestLogfLogln: connection refused`,
+		`^\[E\] .* - TestLogfLogln: via prefix`,
+	} {
+		if !regexp.MustCompile(re).MatchString(lines[i]) {
+			t.Errorf("line %q does not match %q", lines[i], re)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Logf to panic at panic level")
+		}
+	}()
+	Logf(PanicLevel, "boom")
+}
+
+func TestCallerInfoMinLevel(t *testing.T) {
+	defer SetCallerInfoMinLevel(GetCallerInfoMinLevel())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrintSourceInfo(SourceInfoShort)
+	SetCallerInfoMinLevel(WarnLevel)
+	Debugln("cheap")
+	Warnln("expensive")
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), buffer.String())
+	}
+	if strings.Contains(lines[0], "TestCallerInfoMinLevel") || strings.Contains(lines[0], "log_test.go") {
+		t.Errorf("unexpected runtime info in %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "TestCallerInfoMinLevel") || !strings.Contains(lines[1], "(log_test.go:") {
+		t.Errorf("missing runtime info in %q", lines[1])
+	}
+}
+
+// countingStringer counts how many times it is formatted.
+type countingStringer struct {
+	count *int
+}
+
+func (m countingStringer) String() string {
+	*m.count++
+	return "formatted"
+}
+
+func TestDiscardShortCircuit(t *testing.T) {
+	defer WithWriter(io.Discard, false)()
+
+	formatted := 0
+	Infof("value: %v", countingStringer{&formatted})
+	Infoln(countingStringer{&formatted})
+	if formatted != 0 {
+		t.Errorf("expected no formatting, got %d", formatted)
+	}
+
+	records := 0
+	remove := AddRecordHook(func(Record) {
+		records++
+	})
+	defer remove()
+	Infof("value: %v", countingStringer{&formatted})
+	if formatted != 1 || records != 1 {
+		t.Errorf("expected hooks to be notified, got %d formatted and %d records", formatted, records)
+	}
+}
+
+func TestMaxMessageLength(t *testing.T) {
+	defer SetMaxMessageLength(GetMaxMessageLength())
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrintCallerInfo(false)
+	SetPrintSourceInfo(SourceInfoNone)
+	SetMaxMessageLength(8)
+	tests := []struct {
+		message  string
+		expected string
+	}{
+		{"short", " - short\n"},
+		{"exactly8", " - exactly8\n"},
+		{"a longer message\n", " - a longer" + TruncatedMarker + "\n"},
+		// "è" is two bytes long, starting at byte 7
+		{"abcdefgè and more", " - abcdefg" + TruncatedMarker + "\n"},
+	}
+	for _, test := range tests {
+		buffer.Reset()
+		Infof(test.message)
+		if !strings.HasSuffix(buffer.String(), test.expected) {
+			t.Errorf("expected suffix %q, got %q", test.expected, buffer.String())
+		}
+	}
+}
+
+func TestLogAt(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetTimeFormat(GetTimeFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(InfoLevel)
+	SetTimeFormat(time.RFC3339)
+	at := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	LogAt(at, WarnLevel, "replayed %s", "event")
+	LogAt(at, DebugLevel, "filtered out")
+	if !strings.HasPrefix(buffer.String(), "[W] 2001-02-03T04:05:06Z - ") || !strings.Contains(buffer.String(), "TestLogAt: replayed event") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+	if strings.Count(buffer.String(), "\n") != 1 {
+		t.Errorf("expected a single line, got %q", buffer.String())
+	}
+}
+
+func TestLogWithCaller(t *testing.T) {
+	defer SetPrintCallerInfo(GetPrintCallerInfo())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	defer UnmuteSources()
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrintCallerInfo(true)
+	SetPrintSourceInfo(SourceInfoShort)
+	LogWithCaller(WarnLevel, "/src/consumer/queue.go", 42, "consumer.Handle", "replayed %s", "event")
+	if !strings.HasSuffix(buffer.String(), " - consumer.Handle: replayed event (/src/consumer/queue.go:42)\n") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	MuteSource(`queue\.go$`)
+	LogWithCaller(WarnLevel, "/src/consumer/queue.go", 42, "consumer.Handle", "muted")
+	if buffer.Len() != 0 {
+		t.Errorf("expected muted source, got %q", buffer.String())
+	}
+}
+
+func TestSetPrintSourceInfoByName(t *testing.T) {
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+
+	for name, expected := range map[string]int8{
+		"none":    SourceInfoNone,
+		" Short ": SourceInfoShort,
+		"LONG":    SourceInfoLong,
+	} {
+		if err := SetPrintSourceInfoByName(name); err != nil {
+			t.Errorf("unexpected error for %q: %v", name, err)
+		}
+		if GetPrintSourceInfo() != expected {
+			t.Errorf("expected %d for %q, got %d", expected, name, GetPrintSourceInfo())
+		}
+	}
+	SetPrintSourceInfo(SourceInfoLong)
+	if err := SetPrintSourceInfoByName("medium"); err == nil {
+		t.Errorf("expected error for unknown setting")
+	}
+	if GetPrintSourceInfo() != SourceInfoLong {
+		t.Errorf("expected the setting to be unchanged on error")
+	}
+}
+
+func TestColoriseMinLevel(t *testing.T) {
+	defer SetColoriseMinLevel(GetColoriseMinLevel())
+	defer SetForceColorise(GetForceColorise())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, true)()
+
+	SetForceColorise(true)
+	SetColoriseMinLevel(WarnLevel)
+	Infoln("plain")
+	if strings.Contains(buffer.String(), "\x1b[") {
+		t.Errorf("unexpected escape codes in %q", buffer.String())
+	}
+	buffer.Reset()
+	Warnln("coloured")
+	if !strings.HasPrefix(buffer.String(), "\x1b[33m") {
+		t.Errorf("expected escape codes in %q", buffer.String())
+	}
+}
+
+func TestByteCounts(t *testing.T) {
+	defer SetLevel(GetLevel())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(InfoLevel)
+	for _, log := range []func() (int, error){
+		func() (int, error) { return Infof("info") },
+		func() (int, error) { return Fatalf("fatal %d", 1) },
+		func() (int, error) { return Fatalln("fatal", 2) },
+		func() (int, error) { return Logf(FatalLevel, "fatal %d", 3) },
+		func() (int, error) { return WithFields(Int("n", 4)).Fatalf("fatal") },
+	} {
+		buffer.Reset()
+		n, err := log()
+		if err != nil || n == 0 || n != buffer.Len() {
+			t.Errorf("expected %d bytes, got %d (%v)", buffer.Len(), n, err)
+		}
+	}
+	if n, err := Debugf("disabled"); n != 0 || err != nil {
+		t.Errorf("expected 0 bytes for disabled level, got %d (%v)", n, err)
+	}
+}
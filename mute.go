@@ -0,0 +1,77 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"sync"
+)
+
+var (
+	logMutedSources     []*regexp.Regexp
+	logMutedSourcesLock sync.RWMutex
+)
+
+// MuteSource drops all messages logged from a source file or function that
+// matches the given regular expression, e.g. "chatty/component" to silence a
+// noisy package or `_test\.go$` to silence tests; the pattern is matched
+// against the full path of the source file and the fully qualified name of
+// the calling function, regardless of how they are printed. It returns an
+// error if the pattern is not a valid regular expression. Audit records are
+// never muted.
+func MuteSource(pattern string) error {
+	defer changed()
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid source pattern %q: %w", pattern, err)
+	}
+	logMutedSourcesLock.Lock()
+	defer logMutedSourcesLock.Unlock()
+	// copy on write, so that the snapshots never share the array with the next
+	// pattern appended
+	muted := make([]*regexp.Regexp, len(logMutedSources), len(logMutedSources)+1)
+	copy(muted, logMutedSources)
+	logMutedSources = append(muted, re)
+	return nil
+}
+
+// UnmuteSources removes all the patterns registered through MuteSource.
+func UnmuteSources() {
+	defer changed()
+	logMutedSourcesLock.Lock()
+	defer logMutedSourcesLock.Unlock()
+	logMutedSources = nil
+}
+
+// isMuted returns whether the call site skip frames up the stack from the
+// caller of isMuted matches any of the given patterns; the call site is only
+// looked up if there are any.
+func isMuted(patterns []*regexp.Regexp, skip int) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	pc, file, _, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return false
+	}
+	function := ""
+	if f := runtime.FuncForPC(pc); f != nil {
+		function = f.Name()
+	}
+	return mutes(patterns, function, file)
+}
+
+// mutes returns whether the call site with the given function and file matches
+// any of the given patterns.
+func mutes(patterns []*regexp.Regexp, function string, file string) bool {
+	for _, re := range patterns {
+		if re.MatchString(file) || (function != "" && re.MatchString(function)) {
+			return true
+		}
+	}
+	return false
+}
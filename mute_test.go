@@ -0,0 +1,48 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func chattyComponent() {
+	Infoln("chatty message")
+}
+
+func TestMuteSource(t *testing.T) {
+	defer UnmuteSources()
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	if err := MuteSource("("); err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+	if err := MuteSource(`\.chattyComponent$`); err != nil {
+		t.Fatal(err)
+	}
+	chattyComponent()
+	Infoln("quiet message")
+	if strings.Contains(buffer.String(), "chatty") || !strings.Contains(buffer.String(), "quiet message") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	if err := MuteSource(`/mute_test\.go$`); err != nil {
+		t.Fatal(err)
+	}
+	Errorln("muted by file")
+	if buffer.Len() != 0 {
+		t.Errorf("expected no output, got %q", buffer.String())
+	}
+
+	UnmuteSources()
+	chattyComponent()
+	if !strings.Contains(buffer.String(), "chatty message") {
+		t.Errorf("expected output after unmuting, got %q", buffer.String())
+	}
+}
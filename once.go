@@ -0,0 +1,112 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"sync"
+)
+
+// onceKey tracks whether the message with a key was logged through the
+// XxxfOnce functions; the lock serialises the calls with the same key.
+type onceKey struct {
+	sync.Mutex
+	logged bool
+}
+
+// logOnceKeys maps the keys of the XxxfOnce functions to their *onceKey.
+var logOnceKeys sync.Map
+
+// ResetOnce forgets the keys seen by the XxxfOnce functions, so that their
+// messages are logged again.
+func ResetOnce() {
+	logOnceKeys.Range(func(key, _ interface{}) bool {
+		logOnceKeys.Delete(key)
+		return true
+	})
+}
+
+// logfOnce writes the message as logf does, unless a message with the same key
+// has already been written; a message that is not written because its level is
+// not enabled or its source is muted does not count as seen. At PanicLevel, it
+// panics whether the message is written or not, as logf does.
+func logfOnce(level LogLevel, skip int, key string, format string, args []interface{}) (int, error) {
+	if level == PanicLevel {
+		message, fields := sprintf(format, args)
+		if IsPanic() {
+			outputOnce(key, level, skip+1, message, fields)
+		}
+		panic(panicValue(message))
+	}
+	if !isEnabled(level) || discarded(level) {
+		return 0, nil
+	}
+	message, fields := sprintf(format, args)
+	return outputOnce(key, level, skip+1, message, fields)
+}
+
+// outputOnce writes the message as output does, unless a message with the same
+// key has already been written, and records the key once it has.
+func outputOnce(key string, level LogLevel, skip int, message string, fields []Field) (int, error) {
+	value, _ := logOnceKeys.LoadOrStore(key, &onceKey{})
+	once := value.(*onceKey)
+	once.Lock()
+	defer once.Unlock()
+	if once.logged || isMuted(current().muted, skip+1) {
+		return 0, nil
+	}
+	once.logged = true
+	return output(level, skip+1, message, fields)
+}
+
+// TracefOnce writes a trace message to the current output stream, appending a
+// new line, only the first time it is called with the given key (see
+// WarnfOnce).
+func TracefOnce(key string, format string, args ...interface{}) (int, error) {
+	return logfOnce(TraceLevel, 1, key, format, args)
+}
+
+// DebugfOnce writes a debug message to the current output stream, appending a
+// new line, only the first time it is called with the given key (see
+// WarnfOnce).
+func DebugfOnce(key string, format string, args ...interface{}) (int, error) {
+	return logfOnce(DebugLevel, 1, key, format, args)
+}
+
+// InfofOnce writes an informational message to the current output stream,
+// appending a new line, only the first time it is called with the given key
+// (see WarnfOnce).
+func InfofOnce(key string, format string, args ...interface{}) (int, error) {
+	return logfOnce(InfoLevel, 1, key, format, args)
+}
+
+// WarnfOnce writes a warning message to the current output stream, appending a
+// new line, only the first time it is called with the given key, e.g. to report
+// the use of a deprecated feature just once; the keys are shared by all the
+// XxxfOnce functions, and are tracked for the lifetime of the process (see
+// ResetOnce).
+func WarnfOnce(key string, format string, args ...interface{}) (int, error) {
+	return logfOnce(WarnLevel, 1, key, format, args)
+}
+
+// ErrorfOnce writes an error message to the current output stream, appending a
+// new line, only the first time it is called with the given key (see
+// WarnfOnce).
+func ErrorfOnce(key string, format string, args ...interface{}) (int, error) {
+	return logfOnce(ErrorLevel, 1, key, format, args)
+}
+
+// FatalfOnce writes an error message to the current output stream, appending a
+// new line, only the first time it is called with the given key (see
+// WarnfOnce).
+func FatalfOnce(key string, format string, args ...interface{}) (int, error) {
+	return logfOnce(FatalLevel, 1, key, format, args)
+}
+
+// PanicfOnce writes an error message to the current output stream, appending a
+// new line, only the first time it is called with the given key (see
+// WarnfOnce); then it panics, every time, as Panicf does.
+func PanicfOnce(key string, format string, args ...interface{}) (int, error) {
+	return logfOnce(PanicLevel, 1, key, format, args)
+}
@@ -0,0 +1,84 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWarnfOnce(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer ResetOnce()
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(InfoLevel)
+	DebugfOnce("hidden", "not enabled")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			WarnfOnce("deprecated", "option %q is deprecated", "foo")
+		}()
+	}
+	wg.Wait()
+	InfofOnce("deprecated", "same key, different level")
+	if n := strings.Count(buffer.String(), "\n"); n != 1 {
+		t.Fatalf("expected a single line, got %d: %q", n, buffer.String())
+	}
+	if !strings.Contains(buffer.String(), `option "foo" is deprecated`) {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetLevel(DebugLevel)
+	DebugfOnce("hidden", "now enabled")
+	ResetOnce()
+	WarnfOnce("deprecated", "logged again")
+	if n := strings.Count(buffer.String(), "\n"); n != 2 {
+		t.Errorf("expected two lines, got %d: %q", n, buffer.String())
+	}
+}
+
+func TestOnceMuted(t *testing.T) {
+	defer ResetOnce()
+	defer UnmuteSources()
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	if err := MuteSource(`/once_test\.go$`); err != nil {
+		t.Fatal(err)
+	}
+	WarnfOnce("muted", "dropped")
+	UnmuteSources()
+	WarnfOnce("muted", "logged once unmuted")
+	if !strings.Contains(buffer.String(), "logged once unmuted") {
+		t.Errorf("expected the muted message not to count as seen, got %q", buffer.String())
+	}
+}
+
+func TestPanicfOnce(t *testing.T) {
+	defer ResetOnce()
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	for i := 0; i < 2; i++ {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected PanicfOnce to panic on call %d", i+1)
+				}
+			}()
+			PanicfOnce("broken", "invariant broken")
+		}()
+	}
+	if n := strings.Count(buffer.String(), "invariant broken"); n != 1 {
+		t.Errorf("expected a single line, got %d: %q", n, buffer.String())
+	}
+}
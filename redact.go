@@ -0,0 +1,82 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted is the value rendered in place of redacted data.
+const Redacted = "***"
+
+var (
+	logRedactedKeys     = map[string]struct{}{}
+	logRedactedPatterns []*regexp.Regexp
+	logRedactLock       sync.RWMutex
+)
+
+// RegisterRedactedKey registers a key whose values must not appear in the
+// logs: any field with a matching key (compared case-insensitively) is
+// rendered as "***" regardless of its value, in all output formats.
+func RegisterRedactedKey(key string) {
+	defer changed()
+	logRedactLock.Lock()
+	defer logRedactLock.Unlock()
+	logRedactedKeys[strings.ToLower(key)] = struct{}{}
+}
+
+// RegisterRedactedPattern registers a regular expression whose matches in log
+// messages are replaced by "***", e.g. `Bearer \S+`; it returns an error if
+// the pattern cannot be compiled.
+func RegisterRedactedPattern(pattern string) error {
+	defer changed()
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	logRedactLock.Lock()
+	defer logRedactLock.Unlock()
+	logRedactedPatterns = append(logRedactedPatterns, re)
+	return nil
+}
+
+// ClearRedactions removes all the keys and patterns registered through
+// RegisterRedactedKey and RegisterRedactedPattern.
+func ClearRedactions() {
+	defer changed()
+	logRedactLock.Lock()
+	defer logRedactLock.Unlock()
+	logRedactedKeys = map[string]struct{}{}
+	logRedactedPatterns = nil
+}
+
+// redactFields replaces the values of the fields whose keys are registered,
+// also inside groups; groups are copied, since they may be shared with an
+// Entry. It must be called with the lock held.
+func redactFields(fields []Field) {
+	for i, field := range fields {
+		if _, ok := logRedactedKeys[strings.ToLower(field.Key)]; ok {
+			fields[i].Value = Redacted
+		} else if group, ok := field.Value.([]Field); ok {
+			group = append([]Field(nil), group...)
+			redactFields(group)
+			fields[i].Value = group
+		}
+	}
+}
+
+// redact replaces the sensitive data in the record.
+func redact(r *Record) {
+	logRedactLock.RLock()
+	defer logRedactLock.RUnlock()
+	if len(logRedactedKeys) > 0 {
+		redactFields(r.Fields)
+	}
+	for _, re := range logRedactedPatterns {
+		r.Message = re.ReplaceAllString(r.Message, Redacted)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	defer ClearRedactions()
+	defer SetFormat(GetFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	RegisterRedactedKey("X-Secret")
+	if err := RegisterRedactedPattern(`Bearer [A-Za-z0-9]+`); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterRedactedPattern(`(`); err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+
+	for _, format := range []LogFormat{FormatText, FormatJSON, FormatLogfmt} {
+		buffer.Reset()
+		SetFormat(format)
+		Infof("header Authorization: Bearer abc123", Fields{"x-secret": "hunter2", "user": "admin"})
+		if strings.Contains(buffer.String(), "abc123") || strings.Contains(buffer.String(), "hunter2") {
+			t.Errorf("format %d: secret leaked in %q", format, buffer.String())
+		}
+		if !strings.Contains(buffer.String(), "admin") || strings.Count(buffer.String(), Redacted) != 2 {
+			t.Errorf("format %d: unexpected redaction in %q", format, buffer.String())
+		}
+	}
+
+	buffer.Reset()
+	ClearRedactions()
+	Infof("header Authorization: Bearer abc123", Fields{"x-secret": "hunter2"})
+	if !strings.Contains(buffer.String(), "abc123") || !strings.Contains(buffer.String(), "hunter2") {
+		t.Errorf("expected no redaction after clearing in %q", buffer.String())
+	}
+}
+
+func TestRedactGroup(t *testing.T) {
+	defer ClearRedactions()
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	RegisterRedactedKey("password")
+	entry := WithGroup("user").WithFields(Str("name", "joe"), Str("password", "secret"))
+	entry.Infof("login")
+	if strings.Contains(buffer.String(), "secret") || !strings.Contains(buffer.String(), "user.password=***") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+	if entry.fields[0].Value.([]Field)[1].Value != "secret" {
+		t.Errorf("redaction modified the entry")
+	}
+}
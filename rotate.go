@@ -0,0 +1,74 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// logRotateLock is held for reading while a message is written to the stream,
+// so that Rotate can close the old file once the writes in flight are done.
+var logRotateLock sync.RWMutex
+
+// rotator is implemented by streams that can rotate their output on demand,
+// such as rotating file writers.
+type rotator interface {
+	Rotate() error
+}
+
+// Rotate flushes the current stream and rotates it, e.g. when an external tool
+// such as logrotate signals that it has moved the log file away: if the stream
+// has a Rotate() error method, it is called; if it is a regular *os.File whose
+// path no longer refers to the same file (i.e. it was renamed or removed), the
+// file at that path is opened, or created, and it replaces the stream, while
+// the old file is closed, once the messages being written to it are done,
+// unless the stream was replaced in the meantime, in which case the new file is
+// closed instead. Files truncated in place need no action, as long as they
+// were opened in append mode. Rotate is a no-op for other streams, such as
+// terminals and pipes.
+func Rotate() error {
+	if err := Flush(); err != nil {
+		return err
+	}
+	state := logStream.Load()
+	stream, colorise := state.raw, state.colorise
+	switch stream := stream.(type) {
+	case rotator:
+		return stream.Rotate()
+	case *os.File:
+		current, err := stream.Stat()
+		if err != nil {
+			return fmt.Errorf("cannot stat log file: %w", err)
+		}
+		if !current.Mode().IsRegular() {
+			return nil
+		}
+		info, err := os.Stat(stream.Name())
+		if err == nil && os.SameFile(current, info) {
+			return nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot stat log file path: %w", err)
+		}
+		flags := os.O_WRONLY | os.O_APPEND | os.O_CREATE
+		file, err := os.OpenFile(stream.Name(), flags, current.Mode().Perm())
+		if err != nil {
+			return fmt.Errorf("cannot reopen log file: %w", err)
+		}
+		logRotateLock.Lock()
+		defer logRotateLock.Unlock()
+		// replace the stream only if it was not replaced in the meantime
+		if !logStream.CompareAndSwap(state, newStreamState(file, colorise)) {
+			file.Close()
+			return nil
+		}
+		changed()
+		// lines batched for the old file while it was being replaced go there
+		flushPending()
+		return stream.Close()
+	}
+	return nil
+}
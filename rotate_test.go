@@ -0,0 +1,83 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer WithWriter(file, false)()
+
+	Infoln("before rotation")
+	if err := Rotate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if GetStream() != file {
+		t.Fatalf("expected the stream to be unchanged")
+	}
+
+	// simulate logrotate moving the file away
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	Infoln("still in the old file")
+	if err := Rotate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	Infoln("after rotation")
+	defer GetStream().(*os.File).Close()
+
+	old, _ := os.ReadFile(path + ".1")
+	current, _ := os.ReadFile(path)
+	if !strings.Contains(string(old), "before rotation") || !strings.Contains(string(old), "still in the old file") {
+		t.Errorf("unexpected rotated file %q", old)
+	}
+	if !strings.Contains(string(current), "after rotation") || strings.Contains(string(current), "before rotation") {
+		t.Errorf("unexpected current file %q", current)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the new file to keep the permissions, got %v", info.Mode())
+	}
+}
+
+func TestRotateInFlight(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer WithWriter(file, false)()
+
+	// a message whose settings were taken before the rotation
+	s := current()
+	r := newRecord(s, InfoLevel, 1, nil, time.Time{}, "in flight", nil)
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rotate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer GetStream().(*os.File).Close()
+	buffer := &bytes.Buffer{}
+	if _, err := writeRecord(s, r, buffer); err != nil {
+		t.Fatalf("expected the message to be written after the rotation, got %v", err)
+	}
+	if current, _ := os.ReadFile(path); !strings.Contains(string(current), "in flight") {
+		t.Errorf("unexpected current file %q", current)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"sync"
+)
+
+var (
+	logPrintSequence     bool
+	logPrintSequenceLock sync.RWMutex
+	logSequence          uint64
+	logSequenceLock      sync.Mutex
+)
+
+// SetPrintSequence sets whether each line starts with a sequence number, which
+// is incremented atomically for every message written, at any level, so that
+// dropped or reordered lines can be spotted when logs are shipped over a lossy
+// transport; in structured output, it is written under the sequence key. The
+// first message is numbered 1. Numbers are assigned as lines are written, so
+// lines reach the stream in the order of their numbers; as a consequence,
+// goroutines logging concurrently are serialised while the feature is enabled.
+// Streams added with AddStream get the same numbers, but not necessarily in
+// order.
+func SetPrintSequence(enabled bool) {
+	defer changed()
+	logPrintSequenceLock.Lock()
+	defer logPrintSequenceLock.Unlock()
+	logPrintSequence = enabled
+}
+
+// GetPrintSequence returns whether lines start with a sequence number.
+func GetPrintSequence() bool {
+	logPrintSequenceLock.RLock()
+	defer logPrintSequenceLock.RUnlock()
+	return logPrintSequence
+}
+
+// writeRecord renders the record into the buffer and writes it to the stream
+// of the settings, if there is anything to write; if sequence numbers are
+// printed, the record is numbered in the same critical section as the write,
+// so that lines are written in the order of their numbers. If the settings
+// changed after the snapshot was taken, e.g. because the stream was rotated,
+// the line is written with the new ones.
+func writeRecord(s *settings, r *Record, buffer *bytes.Buffer) (int, error) {
+	logRotateLock.RLock()
+	defer logRotateLock.RUnlock()
+	if s.generation != logSettingsGeneration.Load() {
+		s = current()
+	}
+	if s.printSequence {
+		logSequenceLock.Lock()
+		defer logSequenceLock.Unlock()
+		logSequence++
+		r.Sequence = logSequence
+	}
+	if s.formatter != nil {
+		s.formatter.Format(buffer, r)
+	} else {
+		renderColoured(buffer, fit(r), s.format, s.colour(r.Level))
+	}
+	if buffer.Len() == 0 {
+		return 0, nil
+	}
+	return batchWrite(s, r.Level, buffer.Bytes())
+}
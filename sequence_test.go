@@ -0,0 +1,106 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPrintSequence(t *testing.T) {
+	defer SetPrintSequence(GetPrintSequence())
+	defer SetFormat(GetFormat())
+	defer SetLevel(GetLevel())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrintSequence(true)
+	SetLevel(InfoLevel)
+	Infof("first")
+	Debugf("filtered out")
+	Errorf("second")
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	re := regexp.MustCompile(`^#(\d+) \[[IE]\] `)
+	if len(lines) != 2 || !re.MatchString(lines[0]) || !re.MatchString(lines[1]) {
+		t.Fatalf("unexpected output %q", buffer.String())
+	}
+	first, _ := strconv.ParseUint(re.FindStringSubmatch(lines[0])[1], 10, 64)
+	second, _ := strconv.ParseUint(re.FindStringSubmatch(lines[1])[1], 10, 64)
+	if second != first+1 {
+		t.Errorf("expected consecutive sequence numbers, got %d and %d", first, second)
+	}
+
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	Infof("third")
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	if entry["seq"] != float64(second+1) {
+		t.Errorf("unexpected sequence number in %q", buffer.String())
+	}
+}
+
+func TestPrintSequenceConcurrent(t *testing.T) {
+	defer SetPrintSequence(GetPrintSequence())
+	records := map[uint64]bool{}
+	lock := sync.Mutex{}
+	defer AddRecordHook(func(r Record) {
+		lock.Lock()
+		defer lock.Unlock()
+		records[r.Sequence] = true
+	})()
+	defer WithWriter(sink{}, false)()
+
+	SetPrintSequence(true)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Infof("concurrent")
+			}
+		}()
+	}
+	wg.Wait()
+	if len(records) != 800 {
+		t.Errorf("expected 800 unique sequence numbers, got %d", len(records))
+	}
+}
+
+func TestPrintSequenceOrder(t *testing.T) {
+	defer SetPrintSequence(GetPrintSequence())
+	writer := &countingWriter{}
+	defer WithWriter(writer, false)()
+
+	SetPrintSequence(true)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Infof("concurrent")
+			}
+		}()
+	}
+	wg.Wait()
+	output, _ := writer.state()
+	previous := uint64(0)
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		n, err := strconv.ParseUint(strings.TrimPrefix(line[:strings.IndexByte(line, ' ')], "#"), 10, 64)
+		if err != nil || n <= previous {
+			t.Fatalf("expected increasing sequence numbers, got %q after %d", line, previous)
+		}
+		previous = n
+	}
+}
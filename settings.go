@@ -0,0 +1,207 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// settings is a snapshot of the configuration that is read while logging a
+// message: it is taken once per call, so that writing a message synchronises
+// with the setters through a couple of atomic loads instead of taking the lock
+// of every setting it reads, which would cause contention among goroutines
+// logging heavily. Snapshots are immutable and are rebuilt lazily after any of
+// the settings they hold changes.
+type settings struct {
+	// generation is the value of logSettingsGeneration the snapshot was built
+	// for.
+	generation uint64
+
+	// raw is the stream as passed to SetStream, colorise is whether colouring
+	// was requested for it, and stream and colours are the writer messages are
+	// actually written to and the colours of the levels, if it is colourised.
+	raw        io.Writer
+	colorise   bool
+	stream     io.Writer
+	colours    []*color.Color
+	discard    bool
+	terminal   *os.File
+	streams    []*extraStream
+	hooks      []*recordHook
+	muted      []*regexp.Regexp
+	highlights []highlight
+	redacted   bool
+	defaults   []Field
+
+	crashFile          string
+	flushOnLevel       LogLevel
+	batchLines         int
+	batchDelay         time.Duration
+	coloriseMinLevel   LogLevel
+	callerInfoMinLevel LogLevel
+	printCallerInfo    bool
+	callerStyle        int8
+	printPackage       bool
+	unknownCaller      string
+	printSourceInfo    int8
+	printGoroutineID   bool
+	printHostname      bool
+	printStackTrace    LogLevel
+	printSequence      bool
+	maxMessageLength   int
+	timeTruncate       time.Duration
+	clock              func() time.Time
+	testMode           bool
+	printErrorChain    bool
+	highlightJSON      bool
+	wrapToTerminal     bool
+	fatalToStderr      bool
+
+	format        LogFormat
+	formatter     Formatter
+	template      []segment
+	lineTransform func(line string) string
+	lineEnding    LineEnding
+	printLevel    bool
+	tagLeft       string
+	tagRight      string
+	levelFormat   LevelFormat
+	timeFormat    string
+	prefix        string
+	indent        bool
+
+	messageKey    string
+	levelKey      string
+	timeKey       string
+	callerKey     string
+	sourceKey     string
+	goroutineKey  string
+	stackKey      string
+	sequenceKey   string
+	hostKey       string
+	errorChainKey string
+}
+
+var (
+	logSettings           atomic.Pointer[settings]
+	logSettingsGeneration atomic.Uint64
+)
+
+// changed must be called by the setters of the settings held in the snapshot,
+// after the new value is stored, so that the snapshot is rebuilt.
+func changed() {
+	logSettingsGeneration.Add(1)
+}
+
+// current returns the snapshot of the current settings, building it if any of
+// them has changed since the last one was taken.
+func current() *settings {
+	generation := logSettingsGeneration.Load()
+	if s := logSettings.Load(); s != nil && s.generation == generation {
+		return s
+	}
+	s := &settings{generation: generation}
+	state := logStream.Load()
+	s.raw, s.colorise = state.raw, state.colorise
+	s.stream, s.colours, s.discard, s.terminal = state.stream, state.colours, state.discard, state.terminal
+	logStreamsLock.RLock()
+	s.streams = logStreams
+	logStreamsLock.RUnlock()
+	logRecordHooksLock.RLock()
+	s.hooks = logRecordHooks
+	logRecordHooksLock.RUnlock()
+	logMutedSourcesLock.RLock()
+	s.muted = logMutedSources
+	logMutedSourcesLock.RUnlock()
+	logHighlightsLock.RLock()
+	s.highlights = logHighlights
+	logHighlightsLock.RUnlock()
+	logRedactLock.RLock()
+	s.redacted = len(logRedactedKeys) > 0 || len(logRedactedPatterns) > 0
+	logRedactLock.RUnlock()
+	s.defaults = getDefaultFields()
+
+	s.crashFile = GetCrashFile()
+	s.flushOnLevel = GetFlushOnLevel()
+	s.batchLines, s.batchDelay = GetWriteBatch()
+	s.coloriseMinLevel = GetColoriseMinLevel()
+	s.callerInfoMinLevel = GetCallerInfoMinLevel()
+	s.printCallerInfo = GetPrintCallerInfo()
+	s.callerStyle = GetCallerStyle()
+	s.printPackage = GetPrintPackage()
+	s.unknownCaller = GetUnknownCallerPlaceholder()
+	s.printSourceInfo = GetPrintSourceInfo()
+	s.printGoroutineID = GetPrintGoroutineID()
+	s.printHostname = GetPrintHostname()
+	s.printStackTrace = GetPrintStackTrace()
+	s.printSequence = GetPrintSequence()
+	s.maxMessageLength = GetMaxMessageLength()
+	s.timeTruncate = GetTimeTruncate()
+	s.clock = GetClock()
+	s.testMode = GetTestMode()
+	s.printErrorChain = GetPrintErrorChain()
+	s.highlightJSON = GetHighlightJSON()
+	s.wrapToTerminal = GetWrapToTerminal()
+	s.fatalToStderr = GetFatalToStderr()
+
+	s.format = GetFormat()
+	s.formatter = GetFormatter()
+	s.template = getLineTemplate()
+	s.lineTransform = GetLineTransform()
+	s.lineEnding = GetLineEnding()
+	s.printLevel = GetPrintLevel()
+	s.tagLeft, s.tagRight = GetLevelTagStyle()
+	s.levelFormat = GetLevelFormat()
+	s.timeFormat = GetTimeFormat()
+	s.prefix = GetPrefix()
+	s.indent = GetIndentContinuation()
+
+	s.messageKey = GetMessageKey()
+	s.levelKey = GetLevelKey()
+	s.timeKey = GetTimeKey()
+	s.callerKey = GetCallerKey()
+	s.sourceKey = GetSourceKey()
+	s.goroutineKey = GetGoroutineKey()
+	s.stackKey = GetStackKey()
+	s.sequenceKey = GetSequenceKey()
+	s.hostKey = GetHostKey()
+	s.errorChainKey = GetErrorChainKey()
+	logSettings.Store(s)
+	return s
+}
+
+// colour returns the colour of the messages at the given level on the current
+// stream, or nil if they are not coloured.
+func (s *settings) colour(level LogLevel) *color.Color {
+	if s.colours != nil && !s.testMode && level >= TraceLevel && level < NoneLevel && level >= s.coloriseMinLevel {
+		return s.colours[level]
+	}
+	return nil
+}
+
+// levelNameWidth is the length of the longest level name, which level words
+// are padded to in text output so that the following columns line up.
+const levelNameWidth = len("warning")
+
+// tag returns the level tag for text output, rendered and styled as
+// configured.
+func (s *settings) tag(level LogLevel) string {
+	switch s.levelFormat {
+	case LevelWord:
+		name := level.name()
+		return name + strings.Repeat(" ", levelNameWidth-len(name))
+	case LevelSyslogNumber:
+		return strconv.Itoa(level.severity())
+	}
+	return s.tagLeft + level.letter() + s.tagRight
+}
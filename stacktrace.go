@@ -0,0 +1,73 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	logPrintStackTrace     LogLevel
+	logPrintStackTraceLock sync.RWMutex
+)
+
+func init() {
+	SetPrintStackTrace(NoneLevel)
+}
+
+// SetPrintStackTrace sets the minimum level of the messages that are followed
+// by the stack trace of the call site, e.g. ErrorLevel to get a full trace for
+// errors and more severe messages; in text output, the trace is written on the
+// lines after the message, indented, while in structured output it is a field.
+// NoneLevel, which is the default, disables stack traces. NOTE: capturing the
+// stack has an impact on performances, so avoid it for frequent messages.
+func SetPrintStackTrace(minLevel LogLevel) {
+	defer changed()
+	logPrintStackTraceLock.Lock()
+	defer logPrintStackTraceLock.Unlock()
+	logPrintStackTrace = minLevel
+}
+
+// GetPrintStackTrace returns the minimum level of the messages that are
+// followed by the stack trace of the call site.
+func GetPrintStackTrace() LogLevel {
+	logPrintStackTraceLock.RLock()
+	defer logPrintStackTraceLock.RUnlock()
+	return logPrintStackTrace
+}
+
+// stackTrace returns the stack trace starting from the call site skip frames
+// up the stack from the caller of stackTrace, as a line with the function name
+// followed by an indented line with the file and line number for each frame.
+func stackTrace(skip int) string {
+	pcs := make([]uintptr, 32)
+	for {
+		// skip runtime.Callers and stackTrace as well
+		n := runtime.Callers(skip+2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+	var builder strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		builder.WriteString(frame.Function)
+		builder.WriteString("\n\t")
+		builder.WriteString(frame.File)
+		builder.WriteByte(':')
+		builder.WriteString(strconv.Itoa(frame.Line))
+		builder.WriteByte('\n')
+		if !more {
+			break
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,49 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPrintStackTrace(t *testing.T) {
+	defer SetPrintStackTrace(GetPrintStackTrace())
+	defer SetFormat(GetFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetPrintStackTrace(ErrorLevel)
+	Warnf("no trace")
+	Errorf("with trace")
+
+	lines := strings.Split(buffer.String(), "\n")
+	if len(lines) < 4 || !strings.Contains(lines[0], "no trace") || !strings.Contains(lines[1], "with trace") {
+		t.Fatalf("unexpected output %q", buffer.String())
+	}
+	if !regexp.MustCompile(`^\tgithub\.com/.*\.TestPrintStackTrace$`).MatchString(lines[2]) {
+		t.Errorf("expected the call site as the first frame, got %q", lines[2])
+	}
+	if !regexp.MustCompile(`^\t\t.*/stacktrace_test\.go:\d+$`).MatchString(lines[3]) {
+		t.Errorf("expected the indented location of the call site, got %q", lines[3])
+	}
+
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	Errorf("with trace")
+	if strings.Count(buffer.String(), "\n") != 1 {
+		t.Errorf("expected a single line, got %q", buffer.String())
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buffer.String(), err)
+	}
+	if stack, _ := entry["stack"].(string); !strings.Contains(stack, "TestPrintStackTrace\n\t") {
+		t.Errorf("unexpected stack in %q", buffer.String())
+	}
+}
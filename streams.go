@@ -0,0 +1,129 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-colorable"
+)
+
+// StreamOptions is the configuration of a stream added with AddStream.
+type StreamOptions struct {
+	// Colorise requests coloured output, which is applied under the same
+	// conditions as for the main stream (see SetStream).
+	Colorise bool
+	// Format is the format of the messages written to the stream, which is
+	// independent of the format of the main stream (see SetFormat).
+	Format LogFormat
+}
+
+// extraStream is a stream added with AddStream; it is rebuilt, keeping its
+// id, when the colours change.
+type extraStream struct {
+	id      uint64
+	raw     io.Writer
+	options StreamOptions
+	stream  io.Writer
+	colours []*color.Color
+}
+
+var (
+	logStreams     []*extraStream
+	logStreamsID   uint64
+	logStreamsLock sync.RWMutex
+)
+
+// newExtraStream returns the state of an added stream, with the colours of the
+// levels as currently set.
+func newExtraStream(id uint64, stream io.Writer, options StreamOptions) *extraStream {
+	s := &extraStream{id: id, raw: stream, options: options, stream: stream}
+	if options.Colorise && (GetForceColorise() || isTerminal(stream)) {
+		if file, ok := stream.(*os.File); ok {
+			s.stream = colorable.NewColorable(file)
+		}
+		s.colours = make([]*color.Color, NoneLevel)
+		for level := TraceLevel; level < NoneLevel; level++ {
+			s.colours[level] = newColor(level.attributes()...)
+		}
+	}
+	return s
+}
+
+// AddStream adds a stream that receives every message written to the main
+// stream, formatted and coloured according to its own options, e.g. to write
+// coloured text to the terminal and JSON lines to a file from the same calls:
+//
+//	log.SetStream(os.Stderr, true)
+//	log.AddStream(file, log.StreamOptions{Format: log.FormatJSON})
+//
+// It returns a function that removes the stream. Added streams are written
+// after the main stream, directly (i.e. they are never batched) and they are
+// not flushed by Flush; the byte count and the error returned by the logging
+// functions refer to the main stream, while errors writing to added streams
+// are passed to the write error handler, if any (see SetWriteErrorHandler).
+func AddStream(stream io.Writer, options StreamOptions) (remove func()) {
+	defer changed()
+	logStreamsLock.Lock()
+	defer logStreamsLock.Unlock()
+	logStreamsID++
+	s := newExtraStream(logStreamsID, stream, options)
+	// copy on write, so that writeStreams can iterate without holding the lock
+	streams := make([]*extraStream, len(logStreams), len(logStreams)+1)
+	copy(streams, logStreams)
+	logStreams = append(streams, s)
+	return func() {
+		defer changed()
+		logStreamsLock.Lock()
+		defer logStreamsLock.Unlock()
+		streams := make([]*extraStream, 0, len(logStreams))
+		for _, other := range logStreams {
+			if other.id != s.id {
+				streams = append(streams, other)
+			}
+		}
+		logStreams = streams
+	}
+}
+
+// updateStreams rebuilds the state of the added streams, e.g. after the
+// colours have changed.
+func updateStreams() {
+	defer changed()
+	logStreamsLock.Lock()
+	defer logStreamsLock.Unlock()
+	streams := make([]*extraStream, 0, len(logStreams))
+	for _, s := range logStreams {
+		streams = append(streams, newExtraStream(s.id, s.raw, s.options))
+	}
+	logStreams = streams
+}
+
+// writeStreams writes the record to all the streams added when it was created.
+func writeStreams(r *Record) {
+	for _, s := range r.config().streams {
+		var colour *color.Color
+		if s.colours != nil && !r.config().testMode && r.Level >= TraceLevel && r.Level < NoneLevel && r.Level >= r.config().coloriseMinLevel {
+			colour = s.colours[r.Level]
+		}
+		buffer := getBuffer()
+		renderColoured(buffer, r, s.options.Format, colour)
+		var err error
+		if w, ok := s.stream.(LevelWriter); ok {
+			_, err = w.WriteLevel(r.Level, buffer.Bytes())
+		} else {
+			_, err = s.stream.Write(buffer.Bytes())
+		}
+		if err != nil {
+			if handler := GetWriteErrorHandler(); handler != nil {
+				handler(err)
+			}
+		}
+		putBuffer(buffer)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAddStream(t *testing.T) {
+	defer SetForceColorise(GetForceColorise())
+	defer SetWriteErrorHandler(GetWriteErrorHandler())
+	console := &bytes.Buffer{}
+	defer WithWriter(console, true)()
+
+	SetForceColorise(true)
+	file := &bytes.Buffer{}
+	remove := AddStream(file, StreamOptions{Format: FormatJSON})
+	n, err := Infof("dual", Int("answer", 42))
+	if err != nil || n != console.Len() {
+		t.Errorf("expected %d bytes written to the console, got %d (%v)", console.Len(), n, err)
+	}
+	if !strings.HasPrefix(console.String(), "\x1b[") || !strings.Contains(console.String(), "dual answer=42") {
+		t.Errorf("unexpected console output %q", console.String())
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(file.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", file.String(), err)
+	}
+	if entry["msg"] != "dual" || entry["answer"] != float64(42) || entry["level"] != "info" {
+		t.Errorf("unexpected file output %q", file.String())
+	}
+
+	var failures int
+	SetWriteErrorHandler(func(err error) {
+		failures++
+	})
+	defer AddStream(brokenWriter{}, StreamOptions{})()
+	if _, err := Infoln("partly failed"); err != nil || failures != 1 {
+		t.Errorf("expected 1 failure reported to the handler, got %d (%v)", failures, err)
+	}
+
+	remove()
+	file.Reset()
+	Infoln("console only")
+	if file.Len() != 0 {
+		t.Errorf("unexpected output %q after removal", file.String())
+	}
+}
+
+func TestAddStreamDiscard(t *testing.T) {
+	defer WithWriter(io.Discard, false)()
+	file := &bytes.Buffer{}
+	defer AddStream(file, StreamOptions{Format: FormatLogfmt})()
+	Infoln("kept")
+	if !strings.Contains(file.String(), "msg=kept") {
+		t.Errorf("unexpected output %q", file.String())
+	}
+}
+
+func TestAddStreamColours(t *testing.T) {
+	defer SetLevelBold(ErrorLevel, false)
+	defer SetForceColorise(GetForceColorise())
+	defer WithWriter(&bytes.Buffer{}, false)()
+
+	coloured := &bytes.Buffer{}
+	defer AddStream(coloured, StreamOptions{Colorise: true})()
+	Errorln("plain")
+	if strings.Contains(coloured.String(), "\x1b[") {
+		t.Errorf("unexpected colours in %q", coloured.String())
+	}
+
+	coloured.Reset()
+	SetForceColorise(true)
+	SetLevelBold(ErrorLevel, true)
+	Errorln("bold")
+	if !strings.HasPrefix(coloured.String(), "\x1b[31;1m") {
+		t.Errorf("expected bold red output, got %q", coloured.String())
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// placeholder identifies a piece of information about the record in a line
+// template.
+type placeholder int8
+
+const (
+	placeholderNone placeholder = iota
+	placeholderLevel
+	placeholderTime
+	placeholderCaller
+	placeholderSource
+	placeholderMessage
+	placeholderFields
+	placeholderGoroutine
+	placeholderSequence
+	placeholderHost
+	placeholderPrefix
+)
+
+// placeholders maps the names recognised in line templates to placeholders.
+var placeholders = map[string]placeholder{
+	"{level}":     placeholderLevel,
+	"{time}":      placeholderTime,
+	"{caller}":    placeholderCaller,
+	"{source}":    placeholderSource,
+	"{msg}":       placeholderMessage,
+	"{fields}":    placeholderFields,
+	"{goroutine}": placeholderGoroutine,
+	"{seq}":       placeholderSequence,
+	"{host}":      placeholderHost,
+	"{prefix}":    placeholderPrefix,
+}
+
+// segment is a piece of a parsed line template: either some literal text or a
+// placeholder.
+type segment struct {
+	text        string
+	placeholder placeholder
+}
+
+var (
+	logLineTemplate     []segment
+	logLineTemplateLock sync.RWMutex
+)
+
+// SetLineTemplate sets the layout of lines in text format, e.g.
+// "{time} {level} {caller} {msg}"; the recognised placeholders are {level},
+// {time}, {caller} (the calling function), {source} (file and line number),
+// {goroutine}, {seq} (the sequence number), {host} (see SetPrintHostname),
+// {prefix} (see SetPrefix), {msg} and {fields}, and everything else is copied
+// verbatim.
+// Placeholders for runtime information that is not enabled are replaced with
+// an empty string.
+// The template is parsed once; an empty template restores the default layout.
+func SetLineTemplate(template string) {
+	defer changed()
+	var segments []segment
+	for template != "" {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			segments = append(segments, segment{text: template})
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			segments = append(segments, segment{text: template})
+			break
+		}
+		end += start + 1
+		if p, ok := placeholders[template[start:end]]; ok {
+			if start > 0 {
+				segments = append(segments, segment{text: template[:start]})
+			}
+			segments = append(segments, segment{placeholder: p})
+		} else {
+			segments = append(segments, segment{text: template[:end]})
+		}
+		template = template[end:]
+	}
+	logLineTemplateLock.Lock()
+	defer logLineTemplateLock.Unlock()
+	logLineTemplate = segments
+}
+
+// getLineTemplate returns the parsed line template, or nil if the default
+// layout is used.
+func getLineTemplate() []segment {
+	logLineTemplateLock.RLock()
+	defer logLineTemplateLock.RUnlock()
+	return logLineTemplate
+}
+
+// renderTemplate appends the record to the buffer as a line laid out according
+// to the parsed template.
+func renderTemplate(buffer *bytes.Buffer, r *Record, template []segment) {
+	config := r.config()
+	for _, s := range template {
+		switch s.placeholder {
+		case placeholderNone:
+			buffer.WriteString(s.text)
+		case placeholderLevel:
+			buffer.WriteString(config.tag(r.Level))
+		case placeholderTime:
+			if !r.Time.IsZero() {
+				buffer.WriteString(r.Time.Format(config.timeFormat))
+			}
+		case placeholderCaller:
+			buffer.WriteString(r.Function)
+		case placeholderSource:
+			if r.File != "" {
+				buffer.WriteString(r.source())
+			}
+		case placeholderMessage:
+			buffer.WriteString(strings.TrimRight(r.Message, "\r\n"))
+		case placeholderFields:
+			for i, field := range r.Fields {
+				if i > 0 {
+					buffer.WriteByte(' ')
+				}
+				buffer.WriteString(field.String())
+			}
+		case placeholderGoroutine:
+			if r.Goroutine != 0 {
+				buffer.WriteString(strconv.FormatUint(r.Goroutine, 10))
+			}
+		case placeholderSequence:
+			if r.Sequence != 0 {
+				buffer.WriteString(strconv.FormatUint(r.Sequence, 10))
+			}
+		case placeholderHost:
+			buffer.WriteString(r.Host)
+		case placeholderPrefix:
+			buffer.WriteString(config.prefix)
+		}
+	}
+	buffer.WriteByte('\n')
+}
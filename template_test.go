@@ -0,0 +1,49 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestLineTemplate(t *testing.T) {
+	defer SetLineTemplate("")
+	defer SetTimeFormat(GetTimeFormat())
+	defer SetPrintSourceInfo(GetPrintSourceInfo())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetTimeFormat("15:04:05")
+	SetPrintSourceInfo(SourceInfoShort)
+	SetLineTemplate("{time} {level} <{caller}> {msg} {fields} {unknown} @{source}")
+	Warnf("disk full\n", Int("usage", 99))
+
+	re := regexp.MustCompile(`^\d\d:\d\d:\d\d \[W\] <go-log\.TestLineTemplate> disk full usage=99 \{unknown\} @template_test\.go:\d+\n$`)
+	if !re.MatchString(buffer.String()) {
+		t.Errorf("unexpected line %q", buffer.String())
+	}
+
+	buffer.Reset()
+	defer SetPrintHostname(GetPrintHostname())
+	defer SetPrefix(GetPrefix())
+	SetPrintHostname(true)
+	SetPrefix("[db]")
+	SetLineTemplate("{host} {prefix} {msg}")
+	Warnf("disk full")
+	if expected := hostname() + " [db] disk full\n"; buffer.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buffer.String())
+	}
+
+	buffer.Reset()
+	SetLineTemplate("")
+	SetPrintHostname(false)
+	SetPrefix("")
+	Warnf("disk full")
+	if !regexp.MustCompile(`^\[W\] \d\d:\d\d:\d\d - `).MatchString(buffer.String()) {
+		t.Errorf("expected default layout, got %q", buffer.String())
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// defaultTerminalWidth is the width assumed for terminals whose size cannot be
+// determined, if the COLUMNS environment variable does not tell either.
+const defaultTerminalWidth = 80
+
+var (
+	logWrapToTerminal     bool
+	logWrapToTerminalLock sync.RWMutex
+)
+
+// SetWrapToTerminal enables or disables fitting text lines to the width of the
+// terminal, for CLI tools using the logger interactively: when the stream is a
+// terminal and a line would be wider than it, the least important parts are
+// left out, first the source info and then the calling function, so that the
+// message itself wraps as little as possible. The width is queried for each
+// line, so that resizing the terminal is taken into account, falling back to
+// the COLUMNS environment variable, then to 80 columns. Redirected output, the
+// other formats and the streams added with AddStream are not affected.
+func SetWrapToTerminal(enabled bool) {
+	defer changed()
+	logWrapToTerminalLock.Lock()
+	defer logWrapToTerminalLock.Unlock()
+	logWrapToTerminal = enabled
+}
+
+// GetWrapToTerminal returns whether text lines are fitted to the width of the
+// terminal.
+func GetWrapToTerminal() bool {
+	logWrapToTerminalLock.RLock()
+	defer logWrapToTerminalLock.RUnlock()
+	return logWrapToTerminal
+}
+
+// fit returns the record, or a copy of it without the source info and, if
+// still needed, the calling function, so that its text line fits the width of
+// the terminal, if lines are fitted to it.
+func fit(r *Record) *Record {
+	s := r.config()
+	if !s.wrapToTerminal || s.terminal == nil || s.format != FormatText || (r.File == "" && r.Function == "") {
+		return r
+	}
+	width := terminalWidth(s.terminal)
+	line := getBuffer()
+	defer putBuffer(line)
+	renderFormat(line, r, FormatText)
+	if longestLine(line.Bytes()) <= width {
+		return r
+	}
+	fitted := *r
+	fitted.File, fitted.Line = "", 0
+	line.Reset()
+	renderFormat(line, &fitted, FormatText)
+	if longestLine(line.Bytes()) > width {
+		fitted.Function, fitted.unknownCaller = "", false
+	}
+	return &fitted
+}
+
+// longestLine returns the width of the longest line in the text, in runes.
+func longestLine(text []byte) int {
+	longest := 0
+	for len(text) > 0 {
+		line := text
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			line, text = text[:i], text[i+1:]
+		} else {
+			text = nil
+		}
+		if n := utf8.RuneCount(bytes.TrimSuffix(line, []byte("\r"))); n > longest {
+			longest = n
+		}
+	}
+	return longest
+}
+
+// terminalWidth returns the width of the terminal, in columns.
+func terminalWidth(terminal *os.File) int {
+	if width := windowWidth(terminal); width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
@@ -0,0 +1,17 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix && !windows
+
+package log
+
+import (
+	"os"
+)
+
+// windowWidth returns 0, since the size of terminals cannot be determined on
+// this platform.
+func windowWidth(terminal *os.File) int {
+	return 0
+}
@@ -0,0 +1,57 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFit(t *testing.T) {
+	defer SetWrapToTerminal(GetWrapToTerminal())
+	file, err := os.Create(filepath.Join(t.TempDir(), "terminal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	SetWrapToTerminal(true)
+	// pretend the file is a terminal, whose width comes from COLUMNS
+	s := *current()
+	s.terminal = file
+	r := &Record{
+		Level:    InfoLevel,
+		Time:     time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC),
+		Function: "main.run",
+		File:     "/src/app/main.go",
+		Line:     42,
+		Message:  "started",
+		settings: &s,
+	}
+	for columns, expected := range map[string]string{
+		"200": " - main.run: started (/src/app/main.go:42)\n",
+		"50":  " - main.run: started\n",
+		"30":  " - started\n",
+	} {
+		t.Setenv("COLUMNS", columns)
+		buffer := &bytes.Buffer{}
+		renderFormat(buffer, fit(r), FormatText)
+		if !strings.HasSuffix(buffer.String(), expected) {
+			t.Errorf("expected %q at %s columns, got %q", expected, columns, buffer.String())
+		}
+	}
+	if r.File == "" || r.Function == "" {
+		t.Errorf("expected the record not to be modified")
+	}
+
+	s.terminal = nil
+	if fit(r) != r {
+		t.Errorf("expected the record to be left alone on other streams")
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package log
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// windowWidth returns the width of the terminal window, in columns, or 0 if it
+// cannot be determined.
+func windowWidth(terminal *os.File) int {
+	size, err := unix.IoctlGetWinsize(int(terminal.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(size.Col)
+}
@@ -0,0 +1,23 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package log
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// windowWidth returns the width of the console window, in columns, or 0 if it
+// cannot be determined.
+func windowWidth(terminal *os.File) int {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(terminal.Fd()), &info); err != nil {
+		return 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
@@ -0,0 +1,25 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+// Timeit records the current time and returns a function that logs the time
+// elapsed since then at debug level, as an "elapsed" field after the given
+// name, e.g.
+//
+//	defer log.Timeit("loading configuration")()
+//
+// logs "loading configuration elapsed=12.3ms" when the surrounding function
+// returns, reporting it as the call site; the level is checked when the
+// returned function is called, so that Timeit is cheap when debug messages
+// are disabled.
+func Timeit(name string) func() {
+	clock := GetClock()
+	start := clock()
+	return func() {
+		if IsDebug() {
+			output(DebugLevel, 1, name, []Field{Dur("elapsed", clock().Sub(start))})
+		}
+	}
+}
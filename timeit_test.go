@@ -0,0 +1,38 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func timedOperation() {
+	defer Timeit("timed operation")()
+	time.Sleep(time.Millisecond)
+}
+
+func TestTimeit(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetCallerStyle(GetCallerStyle())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetCallerStyle(CallerFuncOnly)
+	timedOperation()
+	re := regexp.MustCompile(`^\[D\] .* - timedOperation: timed operation elapsed=[\d.]+ms \(timeit_test\.go:\d+\)\n$`)
+	if !re.MatchString(buffer.String()) {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetLevel(InfoLevel)
+	timedOperation()
+	if buffer.Len() != 0 {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
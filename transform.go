@@ -0,0 +1,56 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+var (
+	logLineTransform     func(line string) string
+	logLineTransformLock sync.RWMutex
+)
+
+// SetLineTransform sets a function that rewrites each formatted line before it
+// is written, e.g. to mask personal data with a regular expression or to add a
+// prefix; unlike record hooks, which only observe the records, it can change
+// what ends up in the stream, and it applies to every output format. The line
+// is passed without its trailing newline, which is added back afterwards, and
+// may span several lines if the message or the stack trace does. The function
+// runs on the hot path of every message, with no locks held, so it must be
+// fast and safe for concurrent use. Passing nil, which is the default, removes
+// the transform.
+func SetLineTransform(transform func(line string) string) {
+	defer changed()
+	logLineTransformLock.Lock()
+	defer logLineTransformLock.Unlock()
+	logLineTransform = transform
+}
+
+// GetLineTransform returns the function that rewrites the formatted lines, or
+// nil if there is none.
+func GetLineTransform() func(line string) string {
+	logLineTransformLock.RLock()
+	defer logLineTransformLock.RUnlock()
+	return logLineTransform
+}
+
+// transformLine applies the line transform, if not nil, to the line in the
+// buffer from the given offset.
+func transformLine(buffer *bytes.Buffer, start int, transform func(line string) string) {
+	if transform == nil {
+		return
+	}
+	line := string(buffer.Bytes()[start:])
+	newline := strings.HasSuffix(line, "\n")
+	line = transform(strings.TrimSuffix(line, "\n"))
+	buffer.Truncate(start)
+	buffer.WriteString(line)
+	if newline && !strings.HasSuffix(line, "\n") {
+		buffer.WriteByte('\n')
+	}
+}
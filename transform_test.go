@@ -0,0 +1,42 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLineTransform(t *testing.T) {
+	defer SetLineTransform(GetLineTransform())
+	defer SetFormat(GetFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	email := regexp.MustCompile(`[\w.]+@[\w.]+`)
+	SetLineTransform(func(line string) string {
+		return "host1 " + email.ReplaceAllString(line, "<email>")
+	})
+	Infof("sent to %s", "john.doe@example.com")
+	if !strings.HasPrefix(buffer.String(), "host1 [I] ") || !strings.HasSuffix(buffer.String(), ": sent to <email> (transform_test.go:24)\n") {
+		t.Errorf("unexpected text output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetFormat(FormatJSON)
+	Infof("sent to %s", "john.doe@example.com")
+	if !strings.HasPrefix(buffer.String(), "host1 {") || !strings.Contains(buffer.String(), `"msg":"sent to <email>"`) || !strings.HasSuffix(buffer.String(), "}\n") {
+		t.Errorf("unexpected JSON output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	SetLineTransform(nil)
+	Infof("sent to %s", "john.doe@example.com")
+	if !strings.Contains(buffer.String(), "john.doe@example.com") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
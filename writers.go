@@ -0,0 +1,274 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"sync"
+)
+
+// LevelWriter is implemented by streams that need to know the level of each
+// message, e.g. to map it to the severity of syslog, the systemd journal or a
+// cloud logging service without parsing it back out of the line: when the
+// stream, or a stream added with AddStream, implements it, the logger calls
+// WriteLevel instead of Write, with the fully formatted line, and never batches
+// its lines (see SetWriteBatch). Streams that are plain io.Writers are written
+// as usual.
+type LevelWriter interface {
+	WriteLevel(level LogLevel, p []byte) (int, error)
+}
+
+// PrefixWriter returns an io.Writer that splits whatever is written to it into
+// lines and logs each of them through Println, so that lines starting with a
+// level tag such as "[E]" are re-levelled accordingly and the others are
+// written as they are; it is useful to funnel the output of a child process
+// into the logger. Incomplete lines are held until their newline is written.
+func PrefixWriter() io.Writer {
+	return &prefixWriter{}
+}
+
+// prefixWriter is the io.Writer returned by PrefixWriter.
+type prefixWriter struct {
+	lock    sync.Mutex
+	pending []byte
+}
+
+// Write logs each complete line in p and buffers the remainder.
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSuffix(string(w.pending[:i]), "\r")
+		w.pending = w.pending[i+1:]
+		w.println(line)
+	}
+	return len(p), nil
+}
+
+// println routes a single line through Println, splitting the level tag from
+// the text so that Println can delegate to the right logging function; lines
+// tagged with "[P]" are logged at panic level but the panic is recovered,
+// since a child process should not be able to crash the host.
+func (w *prefixWriter) println(line string) {
+	for _, tag := range []string{"[T]", "[D]", "[I]", "[W]", "[E]", "[F]", "[P]"} {
+		if strings.HasPrefix(line, tag) {
+			defer func() {
+				recover()
+			}()
+			Println(tag, strings.TrimLeft(line[len(tag):], " "))
+			return
+		}
+	}
+	Println(line)
+}
+
+// FieldsWriter returns an io.Writer that logs whatever is written to it at the
+// given level with the given fields attached, one message per call to Write,
+// without the trailing newline; it bridges APIs that accept an io.Writer, such
+// as the standard library's log.Logger used for http.Server.ErrorLog, into
+// structured logging, e.g.
+//
+//	server.ErrorLog = stdlog.New(log.FieldsWriter(log.ErrorLevel, log.Fields{"component": "http"}), "", 0)
+//
+// Messages at PanicLevel are logged but the panic is recovered, since the
+// writer's caller should not crash.
+func FieldsWriter(level LogLevel, fields Fields) io.Writer {
+	return &fieldsWriter{level: level, entry: WithFields(fields.sorted()...)}
+}
+
+// fieldsWriter is the io.Writer returned by FieldsWriter.
+type fieldsWriter struct {
+	level LogLevel
+	entry *Entry
+}
+
+// Write logs p as a single message; it returns len(p) on success, since all
+// bytes are consumed.
+func (w *fieldsWriter) Write(p []byte) (n int, err error) {
+	if w.level == PanicLevel {
+		defer func() {
+			if recover() != nil {
+				n, err = len(p), nil
+			}
+		}()
+	}
+	message := strings.TrimSuffix(strings.TrimSuffix(string(p), "\n"), "\r")
+	if _, err := w.entry.logf(w.level, 1, "%s", []interface{}{message}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// GzipWriter returns a writer that compresses what is written to it into the
+// given writer, e.g. an archival log file, and that is safe for concurrent
+// use; once it is set as the stream, Flush writes the compressed data that is
+// still pending to the underlying writer, so that it is not lost if the
+// process crashes. The writer must be closed to write the gzip footer.
+func GzipWriter(w io.Writer) io.WriteCloser {
+	return &gzipWriter{writer: gzip.NewWriter(w)}
+}
+
+// gzipWriter is the io.WriteCloser returned by GzipWriter.
+type gzipWriter struct {
+	lock   sync.Mutex
+	writer *gzip.Writer
+}
+
+// Write compresses p into the underlying writer.
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writer.Write(p)
+}
+
+// Flush writes any pending compressed data to the underlying writer.
+func (w *gzipWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writer.Flush()
+}
+
+// Close flushes any pending compressed data and writes the gzip footer; it
+// does not close the underlying writer.
+func (w *gzipWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.writer.Close()
+}
+
+// LineBufferedWriter returns a writer that holds what is written to it until
+// a newline, then writes all the complete lines to the given writer with a
+// single call, so that a process crashing mid-write is less likely to leave a
+// torn line in a log file; it is safe for concurrent use. Once it is set as the
+// stream, Flush writes any incomplete line that is still pending; Close does
+// the same, but does not close the underlying writer.
+func LineBufferedWriter(w io.Writer) io.WriteCloser {
+	return &lineBufferedWriter{writer: w}
+}
+
+// lineBufferedWriter is the io.WriteCloser returned by LineBufferedWriter.
+type lineBufferedWriter struct {
+	lock   sync.Mutex
+	writer io.Writer
+	buffer []byte
+}
+
+// Write writes the complete lines in p, along with those pending from previous
+// writes, and buffers the remainder; it returns len(p) on success, since all
+// bytes are consumed.
+func (w *lineBufferedWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.buffer = append(w.buffer, p...)
+	if i := bytes.LastIndexByte(w.buffer, '\n'); i >= 0 {
+		if _, err := w.writer.Write(w.buffer[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buffer = w.buffer[:copy(w.buffer, w.buffer[i+1:])]
+	}
+	return len(p), nil
+}
+
+// Flush writes the incomplete line that is still pending, if any.
+func (w *lineBufferedWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if len(w.buffer) == 0 {
+		return nil
+	}
+	_, err := w.writer.Write(w.buffer)
+	w.buffer = w.buffer[:0]
+	return err
+}
+
+// Close writes the incomplete line that is still pending, if any; it does not
+// close the underlying writer.
+func (w *lineBufferedWriter) Close() error {
+	return w.Flush()
+}
+
+// StripANSI returns a writer that removes ANSI escape sequences (such as those
+// setting colours) from whatever is written to it before passing it on to the
+// given writer; it keeps log files clean even when messages embed colours.
+// Sequences split across writes are handled, and the writer is safe for
+// concurrent use.
+func StripANSI(w io.Writer) io.Writer {
+	return &ansiStripper{writer: w}
+}
+
+// ansiState is the state of the escape sequence parser.
+type ansiState int8
+
+const (
+	ansiText ansiState = iota
+	ansiEscape
+	ansiCSI
+	ansiOSC
+	ansiOSCEscape
+)
+
+// ansiStripper is the io.Writer returned by StripANSI.
+type ansiStripper struct {
+	lock   sync.Mutex
+	writer io.Writer
+	state  ansiState
+	buffer []byte
+}
+
+// Write strips escape sequences from p and writes the rest; it returns len(p)
+// on success, since all bytes are consumed.
+func (w *ansiStripper) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.buffer = w.buffer[:0]
+	for _, b := range p {
+		switch w.state {
+		case ansiText:
+			if b == 0x1b {
+				w.state = ansiEscape
+			} else {
+				w.buffer = append(w.buffer, b)
+			}
+		case ansiEscape:
+			switch b {
+			case '[':
+				w.state = ansiCSI
+			case ']':
+				w.state = ansiOSC
+			default:
+				// two-character sequence
+				w.state = ansiText
+			}
+		case ansiCSI:
+			// parameters and intermediate bytes until the final byte
+			if b >= 0x40 && b <= 0x7e {
+				w.state = ansiText
+			}
+		case ansiOSC:
+			// operating system command, terminated by BEL or ESC \
+			if b == 0x07 {
+				w.state = ansiText
+			} else if b == 0x1b {
+				w.state = ansiOSCEscape
+			}
+		case ansiOSCEscape:
+			w.state = ansiText
+		}
+	}
+	if len(w.buffer) > 0 {
+		if _, err := w.writer.Write(w.buffer); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2017-present Andrea Funtò. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	stdlog "log"
+	"strings"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	defer SetLevel(GetLevel())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+	SetLevel(InfoLevel)
+
+	w := PrefixWriter()
+	w.Write([]byte("[E] something failed\n[D] hidden\nplain "))
+	w.Write([]byte("line\n[P] no panic\n"))
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d: %q", len(lines), buffer.String())
+	}
+	if !strings.HasPrefix(lines[0], "[E] ") || !strings.Contains(lines[0], "something failed") {
+		t.Errorf("unexpected error line %q", lines[0])
+	}
+	if lines[1] != "plain line" {
+		t.Errorf("unexpected plain line %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "[P] ") || !strings.Contains(lines[2], "no panic") {
+		t.Errorf("unexpected panic line %q", lines[2])
+	}
+}
+
+func TestFieldsWriter(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetFormat(GetFormat())
+	buffer := &bytes.Buffer{}
+	defer WithWriter(buffer, false)()
+
+	SetLevel(InfoLevel)
+	SetFormat(FormatLogfmt)
+	logger := stdlog.New(FieldsWriter(WarnLevel, Fields{"component": "http"}), "", 0)
+	logger.Printf("TLS handshake error from %s", "10.0.0.1")
+	if !strings.HasPrefix(buffer.String(), "level=warning ") || !strings.HasSuffix(buffer.String(), ` msg="TLS handshake error from 10.0.0.1" component=http`+"\n") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+
+	buffer.Reset()
+	FieldsWriter(DebugLevel, nil).Write([]byte("filtered out\n"))
+	if n, err := FieldsWriter(PanicLevel, nil).Write([]byte("recovered\n")); n != 10 || err != nil {
+		t.Errorf("unexpected result %d, %v", n, err)
+	}
+	if strings.Contains(buffer.String(), "filtered out") || !strings.Contains(buffer.String(), "recovered") {
+		t.Errorf("unexpected output %q", buffer.String())
+	}
+}
+
+func TestGzipWriter(t *testing.T) {
+	compressed := &bytes.Buffer{}
+	w := GzipWriter(compressed)
+	defer WithWriter(w, false)()
+
+	Infoln("compressed message")
+	if err := Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if compressed.Len() == 0 {
+		t.Fatalf("expected compressed data to be flushed")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "compressed message") {
+		t.Errorf("unexpected decompressed data %q", data)
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	w := StripANSI(buffer)
+	w.Write([]byte("\x1b[31mred\x1b[0m and \x1b[1;3"))
+	w.Write([]byte("2mbold green\x1b[0m \x1b]0;title\x07done\n"))
+	if buffer.String() != "red and bold green done\n" {
+		t.Errorf("unexpected stripped output %q", buffer.String())
+	}
+}
+
+func TestLineBufferedWriter(t *testing.T) {
+	counter := &countingWriter{}
+	w := LineBufferedWriter(counter)
+	w.Write([]byte("first li"))
+	if counter.writes != 0 {
+		t.Errorf("expected incomplete line to be buffered, got %q", counter.buffer.String())
+	}
+	w.Write([]byte("ne\nsecond line\nthi"))
+	if counter.writes != 1 || counter.buffer.String() != "first line\nsecond line\n" {
+		t.Errorf("expected complete lines in a single write, got %d writes of %q", counter.writes, counter.buffer.String())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if counter.writes != 2 || counter.buffer.String() != "first line\nsecond line\nthi" {
+		t.Errorf("expected pending bytes to be written on close, got %q", counter.buffer.String())
+	}
+}
+
+// recordingLevelWriter records the level of each write.
+type recordingLevelWriter struct {
+	levels []LogLevel
+}
+
+func (w *recordingLevelWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(NoneLevel, p)
+}
+
+func (w *recordingLevelWriter) WriteLevel(level LogLevel, p []byte) (int, error) {
+	w.levels = append(w.levels, level)
+	return len(p), nil
+}
+
+func TestLevelWriter(t *testing.T) {
+	defer SetWriteBatch(GetWriteBatch())
+	w := &recordingLevelWriter{}
+	defer WithWriter(w, false)()
+
+	SetWriteBatch(10, 0)
+	Infoln("info")
+	Warnln("warning")
+	Errorln("error")
+	if len(w.levels) != 3 || w.levels[0] != InfoLevel || w.levels[1] != WarnLevel || w.levels[2] != ErrorLevel {
+		t.Errorf("unexpected levels %v", w.levels)
+	}
+
+	added := &recordingLevelWriter{}
+	defer AddStream(added, StreamOptions{Format: FormatJSON})()
+	Warnln("warning")
+	if len(added.levels) != 1 || added.levels[0] != WarnLevel {
+		t.Errorf("unexpected levels %v on the added stream", added.levels)
+	}
+}